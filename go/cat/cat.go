@@ -1,9 +1,10 @@
 package cat
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"iter"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -35,20 +36,34 @@ type options struct {
 	KeepHeader     flags.Bool
 	KeepTitle      flags.Bool
 	StripFileLinks flags.Bool
+	Null           flags.Bool
+	StdinTimeout   flags.Duration
 	Help           flags.Bool
 }
 
 func initOpts() options {
 	return options{
-		flags.Glob{nil, ".um filelist. accepts multiple. reads from stdin if not provided"},
-		flags.String{"--base", "-b", "", "base directory prepended to files in filelist"},
-		flags.Bool{"--keep-header", "-d", false, "preserve um headers in concatenated file. overrides --keep-title"},
-		flags.Bool{"--keep-title", "-t", false, "preserve um titles in concatenated file"},
-		flags.Bool{"--strip-file-links", "-s", false, "strip file links in concatenated file"},
-		flags.Bool{"--help", "-h", false, "show help"},
+		flags.Glob{nil, ".um filelist. accepts multiple. reads from stdin if not provided, or for -", "um"},
+		flags.String{"--base", "-b", "", "base directory prepended to files in filelist", "dirs", &flags.Default{}},
+		flags.Bool{"--keep-header", "-d", false, "preserve um headers in concatenated file. overrides --keep-title", &flags.Default{}},
+		flags.Bool{"--keep-title", "-t", false, "preserve um titles in concatenated file", &flags.Default{}},
+		flags.Bool{"--strip-file-links", "-s", false, "strip file links in concatenated file", &flags.Default{}},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
 // remove the um header:
 //
 // # title
@@ -56,9 +71,11 @@ func initOpts() options {
 // + tag
 //
 // optionally keep just the # title
-func decapitate(s string, opts options) string {
+//
+// exported so that um uncat can reproduce the exact sections cat emits.
+func Decapitate(s string, keepHeader, keepTitle bool) string {
 	// if there's no header at all, forget it:
-	if opts.KeepHeader.IsSet() || !strings.HasPrefix(s, H1) {
+	if keepHeader || !strings.HasPrefix(s, H1) {
 		return s
 	}
 	// I'd rather slice and dice than mess with regex
@@ -68,7 +85,7 @@ func decapitate(s string, opts options) string {
 		return head
 	}
 	s = tail
-	if opts.KeepTitle.IsSet() {
+	if keepTitle {
 		// we just take the first line. but when the header consists only of the title, there is no newline:
 		if title, _, ok := strings.Cut(head, pipe.Newline); ok || !strings.Contains(head, pipe.Newline) {
 			s = fmt.Sprintf("%s%s%s", title, DOUBLE_NEWLINE, s)
@@ -91,43 +108,50 @@ func stripFileLinks(s string, opts options) string {
 	return fileLinkRegexp.ReplaceAllString(s, "")
 }
 
-func cat(files []string, opts options) (string, error) {
-	ff := make([]string, 0, len(files))
-	for _, f := range files {
+// writes the files to w one at a time, so that memory stays flat and output begins with the first
+// file.
+//
+// the output is a leading HR_BLOCK, then the files joined by HR_BLOCK. but file links must be stripped
+// as if from that whole string, since such links can occur at the beginning of a file with no leading
+// hr, or at its very end, terminated only by the newline beginning the next HR_BLOCK. so each file is
+// written as HR_BLOCK_STRIP + file + Newline, which is where every link list ends, and each is held
+// back until the next arrives, since the last has no Newline after it.
+func cat(w io.Writer, files iter.Seq2[string, error], opts options) error {
+	// NOTE: prepend leading HR_BLOCK, since these are used for section numbering in both online and print format:
+	if _, err := io.WriteString(w, pipe.Newline); err != nil {
+		return err
+	}
+	pending := ""
+	first := true
+	for f, err := range files {
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, stripFileLinks(HR_BLOCK_STRIP+pending+pipe.Newline, opts)); err != nil {
+				return err
+			}
+		}
+		first = false
 		bf := filepath.Join(opts.Base.Val, f)
 		dat, err := os.ReadFile(bf)
 		if err != nil {
-			return "", fmt.Errorf("error opening target file: %w", err)
+			return fmt.Errorf("error opening target file: %w", err)
 		}
-		s := decapitate(string(dat), opts)
-		ff = append(ff, s)
+		pending = Decapitate(string(dat), opts.KeepHeader.Val, opts.KeepTitle.Val)
 	}
-	// NOTE: prepend leading HR_BLOCK, since these are used for section numbering in both online and print format:
-	catted := HR_BLOCK + strings.Join(ff, HR_BLOCK)
-	// NOTE: strip here, because only the fully catted string will match the file link signature,
-	// since such links can occur at the beginning of a file with no leading hr.
-	catted = stripFileLinks(catted, opts)
-	return catted, nil
+	_, err := io.WriteString(w, stripFileLinks(HR_BLOCK_STRIP+pending, opts))
+	return err
 }
 
-func Cat(args []string) {
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	opts := initOpts()
 	help := flags.NewHelpError(CMD, SUMMARY)
 	if err := flags.ParseArgs(help, args, &opts); err != nil {
-		if errors.As(err, &help) {
-			fmt.Println(help)
-			return
-		}
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
 	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
 	// NOTE: um cat expects .um files, the content of which is assembled:
-	files, err := pipe.FileListFromGlobOrStdin(opts.Filelist.Val)
-	if err != nil {
-		log.Fatalf("um %s: %s", CMD, err)
-	}
-	s, err := cat(files, opts)
-	if err != nil {
-		log.Fatalf("um %s: %s", CMD, err)
-	}
-	fmt.Print(s)
+	files := pipe.FileListSeq(stdin, opts.Filelist.Val, pipe.Sep(opts.Null.Val))
+	return cat(stdout, files, opts)
 }
@@ -0,0 +1,74 @@
+package cat
+
+import (
+	"bytes"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seq(files ...string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for _, f := range files {
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestCat(t *testing.T) {
+	contents := map[string]string{
+		"01.foo.md":   "# 01.foo.md\n: 2024.09.25\n+ bar\n\nFoo.\n",
+		"02.start.md": "100.foo.md\n200.bar.md\n\nStarts with links.\n",
+		"03.end.md":   "# 03.end.md\n\nEnds with links.\n\n---\n\n100.foo.md\n",
+		"04.mid.md":   "Middle.\n\n---\n\n100.foo.md\n\nAfter.\n",
+	}
+	dir := t.TempDir()
+	for f, c := range contents {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte(c), 0664))
+	}
+
+	cases := []struct {
+		name  string
+		files []string
+		strip bool
+		want  string
+	}{
+		{"none", nil, false, "\n---\n\n"},
+		{"one", []string{"01.foo.md"}, false, "\n---\n\nFoo.\n"},
+		{"two", []string{"01.foo.md", "04.mid.md"}, false, "\n---\n\nFoo.\n\n---\n\nMiddle.\n\n---\n\n100.foo.md\n\nAfter.\n"},
+		{"strip start", []string{"01.foo.md", "02.start.md"}, true, "\n---\n\nFoo.\n\nStarts with links.\n"},
+		{"strip end", []string{"03.end.md", "01.foo.md"}, true, "\n---\n\nEnds with links.\n\n---\n\nFoo.\n"},
+		{"strip middle", []string{"04.mid.md"}, true, "\n---\n\nMiddle.\n\nAfter.\n"},
+		{"strip last", []string{"01.foo.md", "03.end.md"}, true, "\n---\n\nFoo.\n\n---\n\nEnds with links.\n\n---\n\n100.foo.md\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := initOpts()
+			opts.Base.Val = dir
+			opts.StripFileLinks.Val = tc.strip
+			w := bytes.Buffer{}
+			assert.NoError(t, cat(&w, seq(tc.files...), opts))
+			assert.Equal(t, tc.want, w.String())
+
+			// the same as stripping the whole output at once:
+			ff := []string{}
+			for _, f := range tc.files {
+				ff = append(ff, Decapitate(contents[f], false, false))
+			}
+			assert.Equal(t, stripFileLinks(HR_BLOCK+strings.Join(ff, HR_BLOCK), opts), w.String())
+		})
+	}
+}
+
+func TestCatMissing(t *testing.T) {
+	opts := initOpts()
+	opts.Base.Val = t.TempDir()
+	err := cat(&bytes.Buffer{}, seq("nope.md"), opts)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -3,11 +3,20 @@ package cmd
 type Subcommand string
 
 const (
-	Tag  Subcommand = "tag"
-	Next Subcommand = "next"
-	Last Subcommand = "last"
-	Sort Subcommand = "sort"
-	Cat  Subcommand = "cat"
-	Mv   Subcommand = "mv"
-	Help Subcommand = "help"
+	Tag        Subcommand = "tag"
+	Next       Subcommand = "next"
+	Last       Subcommand = "last"
+	Sort       Subcommand = "sort"
+	Cat        Subcommand = "cat"
+	Uncat      Subcommand = "uncat"
+	Mv         Subcommand = "mv"
+	Stats      Subcommand = "stats"
+	Graph      Subcommand = "graph"
+	Related    Subcommand = "related"
+	Tags       Subcommand = "tags"
+	Watch      Subcommand = "watch"
+	Serve      Subcommand = "serve"
+	Site       Subcommand = "site"
+	Completion Subcommand = "completion"
+	Help       Subcommand = "help"
 )
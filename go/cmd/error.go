@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// exit statuses, one per kind of failure:
+const (
+	EXIT_OK       = 0
+	EXIT_FAILURE  = 1
+	EXIT_USAGE    = 2
+	EXIT_NOTFOUND = 3
+	EXIT_PARSE    = 4
+	EXIT_IO       = 5
+)
+
+// subcommands wrap these with %w so that main can tell the kind of failure apart from the message.
+var (
+	ErrUsage    = errors.New("usage error")
+	ErrNotFound = errors.New("not found")
+	ErrParse    = errors.New("parse error")
+	ErrIO       = errors.New("i/o error")
+)
+
+// maps an error returned by a subcommand to its exit status. errors from the os count as not found or
+// i/o without being wrapped.
+func ExitCode(err error) int {
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return EXIT_OK
+	case errors.Is(err, ErrUsage):
+		return EXIT_USAGE
+	case errors.Is(err, ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return EXIT_NOTFOUND
+	case errors.Is(err, ErrParse):
+		return EXIT_PARSE
+	case errors.Is(err, ErrIO), errors.As(err, &pathErr):
+		return EXIT_IO
+	}
+	return EXIT_FAILURE
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	_, notExist := os.ReadFile("does/not/exist")
+	_, isDir := os.ReadFile(os.TempDir())
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, EXIT_OK},
+		{"plain", fmt.Errorf("oops"), EXIT_FAILURE},
+		{"usage", fmt.Errorf("%w: --key is required", ErrUsage), EXIT_USAGE},
+		{"not found", fmt.Errorf("%w: um files", ErrNotFound), EXIT_NOTFOUND},
+		{"not exist", fmt.Errorf("error opening file: %w", notExist), EXIT_NOTFOUND},
+		{"parse", fmt.Errorf("outer: %w", fmt.Errorf("%w: bad", ErrParse)), EXIT_PARSE},
+		{"io", fmt.Errorf("%w: stdin", ErrIO), EXIT_IO},
+		{"path error", isDir, EXIT_IO},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ExitCode(tc.err))
+		})
+	}
+}
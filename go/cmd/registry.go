@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// every subcommand runs with injected I/O, so that it can be run and tested in-process. stdin is nil
+// when there is nothing to read.
+type RunFunc func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+type Command struct {
+	Name    Subcommand
+	Aliases []Subcommand
+	Summary string
+	// a pointer to the options struct, for um completion. nil if the command has none.
+	Options func() any
+	Run     RunFunc
+}
+
+// name and alias -> command
+var registry = map[Subcommand]*Command{}
+
+// called from the init of each subcommand package. a name taken twice is a programming error.
+func Register(c Command) {
+	for _, n := range append([]Subcommand{c.Name}, c.Aliases...) {
+		if _, ok := registry[n]; ok {
+			panic(fmt.Sprintf("um: command registered twice: %s", n))
+		}
+		registry[n] = &c
+	}
+}
+
+// finds a command by name or alias.
+func Lookup(name string) (Command, bool) {
+	c, ok := registry[Subcommand(name)]
+	if !ok {
+		return Command{}, false
+	}
+	return *c, true
+}
+
+// all registered commands, sorted by name.
+func Commands() []Command {
+	cc := []Command{}
+	for n, c := range registry {
+		if n == c.Name {
+			cc = append(cc, *c)
+		}
+	}
+	slices.SortFunc(cc, func(a, b Command) int {
+		return strings.Compare(string(a.Name), string(b.Name))
+	})
+	return cc
+}
+
+// commands close enough to name to be what was meant, closest first. aliases count as near misses
+// for the command they belong to.
+func Suggest(name string) []Subcommand {
+	best := map[Subcommand]int{}
+	for n, c := range registry {
+		d := Distance(name, string(n))
+		// a prefix is a good guess however short it is, but a short name is easily mistyped into
+		// another one:
+		near := d <= min(2, len([]rune(n))/2) || (name != "" && strings.HasPrefix(string(n), name))
+		if b, ok := best[c.Name]; near && (!ok || d < b) {
+			best[c.Name] = d
+		}
+	}
+	suggested := slices.Collect(maps.Keys(best))
+	slices.SortFunc(suggested, func(a, b Subcommand) int {
+		if best[a] != best[b] {
+			return best[a] - best[b]
+		}
+		return strings.Compare(string(a), string(b))
+	})
+	return suggested
+}
+
+// levenshtein distance, counted in runes. exported for um tag --suggest-aliases.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func noop(context.Context, []string, io.Reader, io.Writer, io.Writer) error {
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register(Command{Name: Sort, Summary: "sort", Run: noop})
+	Register(Command{Name: Tag, Aliases: []Subcommand{"t"}, Summary: "tag", Run: noop})
+	Register(Command{Name: Cat, Summary: "cat", Run: noop})
+
+	c, ok := Lookup("t")
+	assert.True(t, ok)
+	assert.Equal(t, Tag, c.Name)
+	_, ok = Lookup("nope")
+	assert.False(t, ok)
+
+	names := []Subcommand{}
+	for _, c := range Commands() {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []Subcommand{Cat, Sort, Tag}, names)
+
+	assert.Panics(t, func() { Register(Command{Name: "t", Run: noop}) })
+
+	cases := []struct {
+		name string
+		want []Subcommand
+	}{
+		{"srot", []Subcommand{Sort}},
+		{"ca", []Subcommand{Cat}},
+		{"tg", []Subcommand{Tag}},
+		{"s", []Subcommand{Sort}},
+		{"ta", []Subcommand{Tag}},
+		{"completely wrong", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Suggest(tc.name))
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance("sort", "sort"))
+	assert.Equal(t, 2, Distance("srot", "sort"))
+	assert.Equal(t, 3, Distance("", "cat"))
+	assert.Equal(t, 1, Distance("zettël", "zettel"))
+}
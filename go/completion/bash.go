@@ -0,0 +1,94 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// every --long and -s name of the given flags, optionally only those taking a value.
+func names(ff []Flag, valued bool) string {
+	nn := []string{}
+	for _, f := range ff {
+		if valued && !f.TakesValue {
+			continue
+		}
+		nn = append(nn, f.Long)
+		if f.Short != "" {
+			nn = append(nn, f.Short)
+		}
+	}
+	return strings.Join(nn, " ")
+}
+
+func bashComplete(t target) string {
+	return fmt.Sprintf(`_um_complete %s "$cur" "%s"`, t.Kind, strings.Join(t.Words, " "))
+}
+
+var bashTemplate = template.Must(template.New(BASH).Funcs(template.FuncMap{
+	"names":    names,
+	"complete": bashComplete,
+}).Parse(`# bash completion for um
+# generated by: um completion bash
+
+# counts the positional args before the cursor, skipping flags and the values they take.
+_um_position() {
+    local valued="$1" i n=0 literal=
+    for ((i = 2; i < COMP_CWORD; i++)); do
+        local w="${COMP_WORDS[i]}"
+        if [[ -n $literal ]]; then
+            n=$((n + 1))
+        elif [[ $w == -- ]]; then
+            literal=1
+        elif [[ $w == -* && $w != - ]]; then
+            [[ " $valued " == *" $w "* ]] && i=$((i + 1))
+        else
+            n=$((n + 1))
+        fi
+    done
+    echo "$n"
+}
+
+_um_complete() {
+    local cur="$2"
+    case "$1" in
+    files) COMPREPLY=($(compgen -f -- "$cur")) ;;
+    dirs) COMPREPLY=($(compgen -d -- "$cur")) ;;
+    um) COMPREPLY=($(compgen -f -X '!*.um' -- "$cur") $(compgen -d -- "$cur")) ;;
+    zettel) COMPREPLY=($(compgen -f -X '![0-9]*.md' -- "$cur")) ;;
+    tags) COMPREPLY=($(um completion tags "$cur")) ;;
+    words) COMPREPLY=($(compgen -W "$3" -- "$cur")) ;;
+    *) COMPREPLY=() ;;
+    esac
+}
+
+_um() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    if ((COMP_CWORD == 1)); then
+        COMPREPLY=($(compgen -W "{{range $i, $c := .}}{{if $i}} {{end}}{{$c.Name}}{{end}}" -- "$cur"))
+        return
+    fi
+    case "${COMP_WORDS[1]}" in
+{{- range .}}
+    {{.Name}})
+        case "$prev" in
+{{- range .Flags}}{{if .TakesValue}}
+        {{.Long}}{{if .Short}}|{{.Short}}{{end}}) {{complete .Target}}; return ;;
+{{- end}}{{end}}
+        esac
+        if [[ $cur == -* ]]; then
+            COMPREPLY=($(compgen -W "{{names .Flags false}}" -- "$cur"))
+            return
+        fi
+        case "$(_um_position "{{names .Flags true}}")" in
+{{- range $i, $a := .Args}}
+        {{if $a.Repeated}}*{{else}}{{$i}}{{end}}) {{complete $a.Target}} ;;
+{{- end}}
+        esac
+        ;;
+{{- end}}
+    esac
+}
+
+complete -o filenames -F _um um
+`))
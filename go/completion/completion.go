@@ -0,0 +1,210 @@
+package completion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Completion
+	SUMMARY = "print a shell completion script for bash, zsh or fish"
+)
+
+const (
+	BASH = "bash"
+	ZSH  = "zsh"
+	FISH = "fish"
+	// not a shell: the dynamic tag completion the scripts call back into.
+	TAGS = "tags"
+)
+
+type options struct {
+	Target flags.Arg
+	Word   flags.Arg
+	Help   flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Arg{"", "bash | zsh | fish. or tags to list the tag names completing [word]", "bash|zsh|fish"},
+		flags.Arg{"", "partial tag query to complete", "none"},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// what a flag value or positional completes to.
+type Kind string
+
+const (
+	NONE   Kind = "none"
+	FILES  Kind = "files"
+	DIRS   Kind = "dirs"
+	UM     Kind = "um"
+	ZETTEL Kind = "zettel"
+	TAG    Kind = "tags"
+	WORDS  Kind = "words"
+)
+
+type target struct {
+	Kind  Kind
+	Words []string
+}
+
+// what a flag completes to, from its Complete. a flag without one completes plain files.
+func targetOf(s flags.Spec) (target, error) {
+	if len(s.Words) > 0 {
+		return target{WORDS, s.Words}, nil
+	}
+	switch k := Kind(s.Complete); k {
+	case "":
+		return target{Kind: FILES}, nil
+	case NONE, FILES, DIRS, UM, ZETTEL, TAG:
+		return target{Kind: k}, nil
+	}
+	return target{}, fmt.Errorf("unknown completion for %s: %s", s.Name, s.Complete)
+}
+
+type Flag struct {
+	flags.Spec
+	Target target
+}
+
+type Command struct {
+	Name    cmd.Subcommand
+	Summary string
+	// options with a leading dash
+	Flags []Flag
+	Args  []Flag
+}
+
+// every registered command, with its options resolved to completion targets.
+func commands() ([]Command, error) {
+	registered := cmd.Commands()
+	cc := make([]Command, 0, len(registered))
+	for _, r := range registered {
+		c := Command{Name: r.Name, Summary: r.Summary}
+		if r.Options != nil {
+			specs, err := flags.Specs(r.Options())
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range specs {
+				t, err := targetOf(s)
+				if err != nil {
+					return nil, fmt.Errorf("um %s: %w", r.Name, err)
+				}
+				if s.Positional {
+					c.Args = append(c.Args, Flag{s, t})
+				} else {
+					c.Flags = append(c.Flags, Flag{s, t})
+				}
+			}
+		}
+		cc = append(cc, c)
+	}
+	return cc, nil
+}
+
+// the saved query names, as um tag takes them.
+func savedNames() ([]string, error) {
+	names, err := tag.SavedNames()
+	for i, n := range names {
+		names[i] = tag.SAVED_PREFIX + n
+	}
+	return names, err
+}
+
+// completes the last term of a tag query, keeping whatever terms precede it.
+func completeTags(word string, names []string) []string {
+	i := strings.LastIndexAny(word, string(tag.OR)+string(tag.AND))
+	prefix, term := word[:i+1], word[i+1:]
+	// a negated or exact match is still a tag name:
+	if not, ok := strings.CutPrefix(term, string(tag.NOT)); ok {
+		prefix, term = prefix+string(tag.NOT), not
+	}
+	if exact, ok := strings.CutPrefix(term, string(tag.EXACT)); ok {
+		prefix, term = prefix+string(tag.EXACT), exact
+	}
+	completed := []string{}
+	for _, n := range names {
+		if strings.HasPrefix(n, term) {
+			completed = append(completed, prefix+n)
+		}
+	}
+	return completed
+}
+
+func script(shell string) (string, error) {
+	cc, err := commands()
+	if err != nil {
+		return "", err
+	}
+	var tmpl *template.Template
+	switch shell {
+	case BASH:
+		tmpl = bashTemplate
+	case ZSH:
+		tmpl = zshTemplate
+	case FISH:
+		tmpl = fishTemplate
+	default:
+		return "", fmt.Errorf("%w: unsupported shell: %s", cmd.ErrUsage, shell)
+	}
+	sb := strings.Builder{}
+	if err := tmpl.Execute(&sb, cc); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	// BORK: by hand for now:
+	if !opts.Target.IsSet() {
+		return help.HelpRequired("[target]")
+	}
+
+	if opts.Target.Val == TAGS {
+		names, err := tag.Names(last.GLOB)
+		// a saved query stands alone, so only the whole word can be one:
+		if strings.HasPrefix(opts.Word.Val, tag.SAVED_PREFIX) {
+			names, err = savedNames()
+		}
+		if err != nil {
+			// NOTE: a completion has nowhere useful to report errors:
+			return nil
+		}
+		for _, n := range completeTags(opts.Word.Val, names) {
+			fmt.Fprintln(stdout, n)
+		}
+		return nil
+	}
+	s, err := script(opts.Target.Val)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, s)
+	return nil
+}
@@ -0,0 +1,103 @@
+package completion
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brtholomy/um/go/config"
+	"github.com/brtholomy/um/go/flags"
+	_ "github.com/brtholomy/um/go/sort"
+)
+
+func TestCompleteTags(t *testing.T) {
+	names := []string{"bar", "diff", "foo", "science", "science/physics"}
+	cases := []struct {
+		word string
+		want []string
+	}{
+		{"", names},
+		{"s", []string{"science", "science/physics"}},
+		{"science/", []string{"science/physics"}},
+		{"bar+=sc", []string{"bar+=science", "bar+=science/physics"}},
+		{"foo+!sc", []string{"foo+!science", "foo+!science/physics"}},
+		{"foo+!=science/", []string{"foo+!=science/physics"}},
+		{"bar,f", []string{"bar,foo"}},
+		{"bar+foo+", []string{"bar+foo+bar", "bar+foo+diff", "bar+foo+foo", "bar+foo+science", "bar+foo+science/physics"}},
+		{"qux", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.word, func(t *testing.T) {
+			assert.Equal(t, tc.want, completeTags(tc.word, names))
+		})
+	}
+}
+
+func TestCompleteSaved(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	conf := filepath.Join(dir, "config.toml")
+	t.Setenv(config.ENV, conf)
+	assert.NoError(t, os.WriteFile(conf, []byte("[query.essays]\nquery = essay\n"), 0664))
+	assert.NoError(t, os.WriteFile("drafts.um", []byte("# query = draft\n"), 0664))
+	assert.NoError(t, os.WriteFile("plain.um", []byte("01.md\n"), 0664))
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, Run(context.Background(), []string{TAGS, "@"}, nil, &buf, &buf))
+	assert.Equal(t, "@drafts\n@essays\n", buf.String())
+}
+
+func TestTargetOf(t *testing.T) {
+	cases := []struct {
+		spec flags.Spec
+		want target
+	}{
+		{flags.Spec{}, target{Kind: FILES}},
+		{flags.Spec{Complete: "um"}, target{Kind: UM}},
+		{flags.Spec{Words: []string{"a", "b"}}, target{WORDS, []string{"a", "b"}}},
+	}
+	for _, tc := range cases {
+		got, err := targetOf(tc.spec)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+	_, err := targetOf(flags.Spec{Name: "key", Complete: "nope"})
+	assert.EqualError(t, err, "unknown completion for key: nope")
+}
+
+func TestScript(t *testing.T) {
+	cases := []struct {
+		shell string
+		want  []string
+	}{
+		{BASH, []string{
+			`        --key|-k) _um_complete um "$cur" ""; return ;;`,
+			`        0) _um_complete tags "$cur" "" ;;`,
+			`complete -o filenames -F _um um`,
+		}},
+		{ZSH, []string{
+			`'(--key -k)'{--key,-k}'[path to sort key]:key:_files -g "*.um"' \`,
+			`'1:query:_um_tags' \`,
+			`        sort) _um_sort ;;`,
+		}},
+		{FISH, []string{
+			`complete -c um -n '__fish_seen_subcommand_from sort' -l key -s k -r -a '(__fish_complete_suffix .um)' -d 'path to sort key'`,
+			`complete -c um -n '__fish_seen_subcommand_from tag' -a '(um completion tags (commandline -ct))'`,
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.shell, func(t *testing.T) {
+			s, err := script(tc.shell)
+			assert.NoError(t, err)
+			for _, w := range tc.want {
+				assert.Contains(t, s, w)
+			}
+		})
+	}
+	_, err := script("csh")
+	assert.Error(t, err)
+}
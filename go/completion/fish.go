@@ -0,0 +1,60 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+var fishEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func fishAction(t target) string {
+	switch t.Kind {
+	case NONE:
+		return ""
+	case DIRS:
+		return "-a '(__fish_complete_directories (commandline -ct))'"
+	case UM:
+		return "-a '(__fish_complete_suffix .um)'"
+	case ZETTEL:
+		return "-a '(__fish_complete_suffix .md)'"
+	case TAG:
+		return "-a '(um completion tags (commandline -ct))'"
+	case WORDS:
+		return fmt.Sprintf("-a '%s'", strings.Join(t.Words, " "))
+	}
+	return "-F"
+}
+
+func fishFlag(f Flag) string {
+	s := "-l " + strings.TrimPrefix(f.Long, "--")
+	if f.Short != "" {
+		s += " -s " + strings.TrimPrefix(f.Short, "-")
+	}
+	if f.TakesValue {
+		s += " -r"
+		if a := fishAction(f.Target); a != "" {
+			s += " " + a
+		}
+	}
+	return s + fmt.Sprintf(" -d '%s'", fishEscaper.Replace(f.Help))
+}
+
+// NOTE: fish has no simple notion of position, so every positional of a command is offered at once.
+var fishTemplate = template.Must(template.New(FISH).Funcs(template.FuncMap{
+	"flag":   fishFlag,
+	"action": fishAction,
+	"escape": fishEscaper.Replace,
+}).Parse(`# fish completion for um
+# generated by: um completion fish
+
+complete -c um -f
+{{range .}}
+complete -c um -n __fish_use_subcommand -a {{.Name}} -d '{{escape .Summary}}'
+{{- end}}
+{{range $c := .}}{{range .Flags}}
+complete -c um -n '__fish_seen_subcommand_from {{$c.Name}}' {{flag .}}
+{{- end}}{{range .Args}}{{with action .Target}}
+complete -c um -n '__fish_seen_subcommand_from {{$c.Name}}' {{.}}
+{{- end}}{{end}}{{end}}
+`))
@@ -0,0 +1,113 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+var zshEscaper = strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`)
+
+func zshAction(t target) string {
+	switch t.Kind {
+	case NONE:
+		return " "
+	case DIRS:
+		return "_files -/"
+	case UM:
+		return `_files -g "*.um"`
+	case ZETTEL:
+		return `_files -g "[0-9]*.md"`
+	case TAG:
+		return "_um_tags"
+	case WORDS:
+		return fmt.Sprintf("(%s)", strings.Join(t.Words, " "))
+	}
+	return "_files"
+}
+
+// an _arguments spec for a flag: '(--long -s)'{--long,-s}'[help]:name:action'
+func zshFlag(f Flag) string {
+	sb := strings.Builder{}
+	switch {
+	case f.Repeated:
+		sb.WriteString("'*'")
+	case f.Short != "":
+		sb.WriteString(fmt.Sprintf("'(%s %s)'", f.Long, f.Short))
+	}
+	if f.Short != "" {
+		sb.WriteString(fmt.Sprintf("{%s,%s}'", f.Long, f.Short))
+	} else {
+		sb.WriteString(fmt.Sprintf("'%s", f.Long))
+	}
+	sb.WriteString(fmt.Sprintf("[%s]", zshEscaper.Replace(f.Help)))
+	if f.TakesValue {
+		sb.WriteString(fmt.Sprintf(":%s:%s", f.Name, zshAction(f.Target)))
+	}
+	sb.WriteString("'")
+	return sb.String()
+}
+
+// an _arguments spec for a positional, counted from 1: 'n:name:action'
+func zshArg(i int, f Flag) string {
+	n := fmt.Sprint(i + 1)
+	if f.Repeated {
+		n = "*"
+	}
+	return fmt.Sprintf("'%s:%s:%s'", n, f.Name, zshAction(f.Target))
+}
+
+var zshTemplate = template.Must(template.New(ZSH).Funcs(template.FuncMap{
+	"flag":   zshFlag,
+	"arg":    zshArg,
+	"escape": zshEscaper.Replace,
+}).Parse(`#compdef um
+# zsh completion for um
+# generated by: um completion zsh
+
+_um_tags() {
+    local -a tags
+    tags=(${(f)"$(um completion tags "$PREFIX")"})
+    compadd -- $tags
+}
+{{range .}}{{if or .Flags .Args}}
+_um_{{.Name}}() {
+    _arguments -s \
+{{- range .Flags}}
+        {{flag .}} \
+{{- end}}
+{{- range $i, $a := .Args}}
+        {{arg $i $a}} \
+{{- end}}
+
+}
+{{end}}{{end}}
+_um() {
+    local line state
+    _arguments -C '1: :->cmds' '*:: :->args'
+    case $state in
+    cmds)
+        local -a cmds
+        cmds=(
+{{- range .}}
+            '{{.Name}}:{{escape .Summary}}'
+{{- end}}
+        )
+        _describe command cmds
+        ;;
+    args)
+        case $line[1] in
+{{- range .}}{{if or .Flags .Args}}
+        {{.Name}}) _um_{{.Name}} ;;
+{{- end}}{{end}}
+        esac
+        ;;
+    esac
+}
+
+if [ "$funcstack[1]" = "_um" ]; then
+    _um "$@"
+else
+    compdef _um um
+fi
+`))
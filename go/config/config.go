@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+)
+
+const (
+	// overrides the search for a config file:
+	ENV = "UM_CONFIG"
+	// looked for in the current directory, which is the collection:
+	FILE = ".um.toml"
+	// and otherwise in the user config dir:
+	USER_FILE = "um/config.toml"
+)
+
+// wraps cmd.ErrParse without changing the message.
+type ParseError struct {
+	message string
+}
+
+func (pe ParseError) Error() string {
+	return pe.message
+}
+
+func (pe ParseError) Unwrap() error {
+	return cmd.ErrParse
+}
+
+func parseError(name string, n int, format string, a ...any) error {
+	return ParseError{fmt.Sprintf("%s:%d: ", name, n+1) + fmt.Sprintf(format, a...)}
+}
+
+// a small subset of TOML: [section] headers, key = value pairs, and # comments. values are either
+// bare or double quoted strings.
+//
+// section -> key -> value
+type Config map[string]map[string]string
+
+// the config file in effect, or "" if there is none.
+func Path() string {
+	if p := os.Getenv(ENV); p != "" {
+		return p
+	}
+	if _, err := os.Stat(FILE); err == nil {
+		return FILE
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		p := filepath.Join(dir, USER_FILE)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// loads the config at Path(). a missing config is empty rather than an error, unless it was named
+// explicitly by UM_CONFIG.
+func Load() (Config, error) {
+	p := Path()
+	if p == "" {
+		return Config{}, nil
+	}
+	dat, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && os.Getenv(ENV) == "" {
+			return Config{}, nil
+		}
+		return nil, fmt.Errorf("error opening config: %w", err)
+	}
+	return Parse(p, string(dat))
+}
+
+// name is only used for error messages.
+func Parse(name string, s string) (Config, error) {
+	c := Config{}
+	section := ""
+	for n, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, parseError(name, n, "unterminated section: %s", line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, parseError(name, n, "expected key = value: %s", line)
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if strings.HasPrefix(val, `"`) {
+			// a quoted string may itself contain a #, so only a comment after the closing quote is cut:
+			end := closingQuote(val)
+			if end < 0 {
+				return nil, parseError(name, n, "unterminated string: %s", val)
+			}
+			unq, err := strconv.Unquote(val[:end+1])
+			if err != nil {
+				return nil, parseError(name, n, "%s: %s", err, val)
+			}
+			val = unq
+		} else if v, _, ok := strings.Cut(val, " #"); ok {
+			val = strings.TrimSpace(v)
+		}
+		if _, ok := c[section]; !ok {
+			c[section] = map[string]string{}
+		}
+		c[section][key] = val
+	}
+	return c, nil
+}
+
+// index of the quote closing the string opened at s[0], skipping escapes.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func (c Config) Get(section, key string) (string, bool) {
+	v, ok := c[section][key]
+	return v, ok
+}
+
+// all keys of a section. nil if absent.
+func (c Config) Section(section string) map[string]string {
+	return c[section]
+}
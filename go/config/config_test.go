@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	s := `# um config
+top = level
+
+[cat]
+base = "../drafts" # trailing comment
+keep-title = true
+
+[tag]
+date = 2024.01.01-2024.12.31 # bare value
+hash = "a # b"
+`
+	c, err := Parse("test", s)
+	assert.NoError(t, err)
+	assert.Equal(t, Config{
+		"":    {"top": "level"},
+		"cat": {"base": "../drafts", "keep-title": "true"},
+		"tag": {"date": "2024.01.01-2024.12.31", "hash": "a # b"},
+	}, c)
+
+	v, ok := c.Get("cat", "base")
+	assert.True(t, ok)
+	assert.Equal(t, "../drafts", v)
+	_, ok = c.Get("sort", "key")
+	assert.False(t, ok)
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"section", "[cat\n", "test:1: unterminated section: [cat"},
+		{"pair", "\n[cat]\nbase\n", "test:3: expected key = value: base"},
+		{"string", "base = \"../\n", "test:1: unterminated string: \"../"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse("test", tc.s)
+			assert.EqualError(t, err, tc.want)
+			assert.ErrorIs(t, err, cmd.ErrParse)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+)
+
+// where the effective value of a flag came from, in increasing precedence.
+type Source string
+
+const (
+	DEFAULT Source = "default"
+	CONFIG  Source = "config"
+	ENV     Source = "env"
+	CLI     Source = "cli"
+)
+
+type origin struct {
+	source Source
+	// the env var or config key, if any:
+	name string
+}
+
+func (o origin) String() string {
+	if o.name == "" {
+		return string(o.source)
+	}
+	return fmt.Sprintf("%s %s", o.source, o.name)
+}
+
+// the env var backing a flag which declares none: UM_CAT_BASE for um cat --base
+func EnvName(sub cmd.Subcommand, long string) string {
+	name := fmt.Sprintf("UM_%s_%s", sub, strings.TrimPrefix(long, "--"))
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// the config key backing a flag which declares none, under the [sub] section: base for um cat --base
+func ConfigKey(long string) string {
+	return strings.TrimPrefix(long, "--")
+}
+
+// the specs of the flags taking defaults.
+func defaulted(specs []Spec) []Spec {
+	dd := []Spec{}
+	for _, s := range specs {
+		if s.Defaults {
+			dd = append(dd, s)
+		}
+	}
+	return dd
+}
+
+// sets flags from the config and then the environment, so that env takes precedence over config.
+// args are parsed afterwards and override both.
+//
+// NOTE: only flags with a Default have defaults, and the config isn't read without one.
+func applyDefaults(help HelpError, opts any, flags []Flag) error {
+	specs, err := Specs(opts)
+	if err != nil {
+		return err
+	}
+	if len(defaulted(specs)) == 0 {
+		return nil
+	}
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+	for j, f := range flags {
+		s := specs[j]
+		if !s.Defaults {
+			continue
+		}
+		key := s.ConfigKey()
+		if val, ok := conf.Get(string(help.sub), key); ok {
+			if err := f.Set(val); err != nil {
+				return help.HelpInvalidValue(fmt.Sprintf("[%s] %s", help.sub, key), val)
+			}
+			help.origins[f] = origin{CONFIG, fmt.Sprintf("[%s] %s", help.sub, key)}
+		}
+		env := s.EnvName(help.sub)
+		if val, ok := os.LookupEnv(env); ok {
+			if err := f.Set(val); err != nil {
+				return help.HelpInvalidValue(env, val)
+			}
+			help.origins[f] = origin{ENV, env}
+		}
+	}
+	return nil
+}
@@ -1,9 +1,12 @@
 package flags
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const PARSE_ERROR_PREFIX = "internal err: ParseArgs"
@@ -16,50 +19,107 @@ func (pe ParseError) Error() string {
 	return fmt.Sprintf("%s: %s", PARSE_ERROR_PREFIX, pe.message)
 }
 
+// ends option parsing: everything after is positional, even with a leading dash.
+const TERMINATOR = "--"
+
 // interface as func parameter
 type Flag interface {
-	Set(string)
+	Set(string) error
 	Valid([]string, int) bool
 	MaybeIncrement(int) int
 	Match(string, int, int) bool
+	Positional() bool
 	IsSet() bool
 	IsHelp() bool
+	// what um completion offers for its value, as for Spec.
+	Completes() string
+	// where it takes a default from, nil for none.
+	Defaults() *Default
+}
+
+// opts a flag in to a default from the env and then the config, besides the value given in initOpts.
+// a flag which acts, like --write, is better left without, so that no env var makes it act every
+// time. an empty Env or Key is derived from the long name, by EnvName and ConfigKey.
+type Default struct {
+	// the env var:
+	Env string
+	// the key under the [sub] section of the config:
+	Key string
 }
 
 type Arg struct {
-	Val  string
-	Help string
+	Val      string
+	Help     string
+	Complete string
 }
 
 type Glob struct {
-	Val  []string
-	Help string
+	Val      []string
+	Help     string
+	Complete string
 }
 
 type String struct {
-	Long  string
-	Short string
-	Val   string
-	Help  string
+	Long     string
+	Short    string
+	Val      string
+	Help     string
+	Complete string
+	Default  *Default
+}
+
+// a string flag which may be repeated, collecting each value.
+type Strings struct {
+	Long     string
+	Short    string
+	Val      []string
+	Help     string
+	Complete string
+	Default  *Default
+}
+
+type Int struct {
+	Long     string
+	Short    string
+	Val      int
+	Help     string
+	Complete string
+	Default  *Default
+}
+
+type Duration struct {
+	Long     string
+	Short    string
+	Val      time.Duration
+	Help     string
+	Complete string
+	Default  *Default
 }
 
 type Bool struct {
-	Long  string
-	Short string
-	Val   bool
-	Help  string
+	Long    string
+	Short   string
+	Val     bool
+	Help    string
+	Default *Default
 }
 
-func (f *Arg) Set(arg string) {
+func (f *Arg) Set(arg string) error {
 	f.Val = arg
+	return nil
 }
 
 func (f *Arg) Valid(args []string, i int) bool {
 	return true
 }
 
+// NOTE: i is the count of positional args seen so far, not the index into args.
 func (f *Arg) Match(arg string, i, j int) bool {
-	return i == j && !hasDashPrefix(arg)
+	return i == j
+}
+
+func (f *Arg) Positional() bool {
+	return true
 }
 
 func (f *Arg) IsSet() bool {
@@ -74,8 +134,9 @@ func (f *Arg) MaybeIncrement(i int) int {
 	return i
 }
 
-func (f *Glob) Set(arg string) {
+func (f *Glob) Set(arg string) error {
 	f.Val = append(f.Val, arg)
+	return nil
 }
 
 func (f *Glob) Valid(args []string, i int) bool {
@@ -85,7 +146,11 @@ func (f *Glob) Valid(args []string, i int) bool {
 func (f *Glob) Match(arg string, i, j int) bool {
 	// NOTE: this is the key difference from Arg: by not requiring that the arg and flag positions
 	// match, a shell-expanded glob of files of any length will populate the value.
-	return i >= j && !hasDashPrefix(arg)
+	return i >= j
+}
+
+func (f *Glob) Positional() bool {
+	return true
 }
 
 func (f *Glob) IsSet() bool {
@@ -101,12 +166,13 @@ func (f *Glob) MaybeIncrement(i int) int {
 	return i
 }
 
-func (f *String) Set(arg string) {
+func (f *String) Set(arg string) error {
 	f.Val = arg
+	return nil
 }
 
 func (f *String) Valid(args []string, i int) bool {
-	return !missingValue(args, i)
+	return !missingValue(args, i, nil)
 }
 
 func (f *String) MaybeIncrement(i int) int {
@@ -117,6 +183,10 @@ func (f *String) Match(arg string, _, _ int) bool {
 	return arg == f.Long || arg == f.Short
 }
 
+func (f *String) Positional() bool {
+	return false
+}
+
 func (f *String) IsSet() bool {
 	return f.Val != ""
 }
@@ -125,8 +195,120 @@ func (f *String) IsHelp() bool {
 	return false
 }
 
-func (f *Bool) Set(arg string) {
-	f.Val = true
+func (f *Strings) Set(arg string) error {
+	f.Val = append(f.Val, arg)
+	return nil
+}
+
+func (f *Strings) Valid(args []string, i int) bool {
+	return !missingValue(args, i, nil)
+}
+
+func (f *Strings) MaybeIncrement(i int) int {
+	return i + 1
+}
+
+func (f *Strings) Match(arg string, _, _ int) bool {
+	return arg == f.Long || arg == f.Short
+}
+
+func (f *Strings) Positional() bool {
+	return false
+}
+
+func (f *Strings) IsSet() bool {
+	return len(f.Val) != 0
+}
+
+func (f *Strings) IsHelp() bool {
+	return false
+}
+
+func (f *Int) Set(arg string) error {
+	i, err := strconv.Atoi(arg)
+	if err != nil {
+		return err
+	}
+	f.Val = i
+	return nil
+}
+
+func (f *Int) Valid(args []string, i int) bool {
+	return !missingValue(args, i, func(s string) bool {
+		_, err := strconv.Atoi(s)
+		return err == nil
+	})
+}
+
+func (f *Int) MaybeIncrement(i int) int {
+	return i + 1
+}
+
+func (f *Int) Match(arg string, _, _ int) bool {
+	return arg == f.Long || arg == f.Short
+}
+
+func (f *Int) Positional() bool {
+	return false
+}
+
+func (f *Int) IsSet() bool {
+	return f.Val != 0
+}
+
+func (f *Int) IsHelp() bool {
+	return false
+}
+
+func (f *Duration) Set(arg string) error {
+	d, err := time.ParseDuration(arg)
+	if err != nil {
+		return err
+	}
+	f.Val = d
+	return nil
+}
+
+func (f *Duration) Valid(args []string, i int) bool {
+	return !missingValue(args, i, func(s string) bool {
+		_, err := time.ParseDuration(s)
+		return err == nil
+	})
+}
+
+func (f *Duration) MaybeIncrement(i int) int {
+	return i + 1
+}
+
+func (f *Duration) Match(arg string, _, _ int) bool {
+	return arg == f.Long || arg == f.Short
+}
+
+func (f *Duration) Positional() bool {
+	return false
+}
+
+func (f *Duration) IsSet() bool {
+	return f.Val != 0
+}
+
+func (f *Duration) IsHelp() bool {
+	return false
+}
+
+// on the commandline a bool sees only its own name, unless it's given a value as --write=false, which
+// turns off a default of true.
+func (f *Bool) Set(arg string) error {
+	if arg == f.Long || arg == f.Short {
+		f.Val = true
+		return nil
+	}
+	v, err := strconv.ParseBool(arg)
+	if err != nil {
+		return err
+	}
+	f.Val = v
+	return nil
 }
 
 func (f *Bool) Match(arg string, _, _ int) bool {
@@ -141,6 +323,10 @@ func (f *Bool) MaybeIncrement(i int) int {
 	return i
 }
 
+func (f *Bool) Positional() bool {
+	return false
+}
+
 func (f *Bool) IsSet() bool {
 	return f.Val
 }
@@ -149,13 +335,99 @@ func (f *Bool) IsHelp() bool {
 	return f.Long == "--help"
 }
 
-func hasDashPrefix(s string) bool {
-	return strings.HasPrefix(s, "-")
+func (f *Arg) Completes() string {
+	return f.Complete
+}
+
+func (f *Arg) Defaults() *Default {
+	return nil
+}
+
+func (f *Glob) Completes() string {
+	return f.Complete
+}
+
+func (f *Glob) Defaults() *Default {
+	return nil
+}
+
+func (f *String) Completes() string {
+	return f.Complete
+}
+
+func (f *String) Defaults() *Default {
+	return f.Default
+}
+
+func (f *Strings) Completes() string {
+	return f.Complete
+}
+
+func (f *Strings) Defaults() *Default {
+	return f.Default
+}
+
+func (f *Int) Completes() string {
+	return f.Complete
+}
+
+func (f *Int) Defaults() *Default {
+	return f.Default
+}
+
+func (f *Duration) Completes() string {
+	return f.Complete
+}
+
+func (f *Duration) Defaults() *Default {
+	return f.Default
+}
+
+func (f *Bool) Completes() string {
+	return ""
+}
+
+func (f *Bool) Defaults() *Default {
+	return f.Default
 }
 
-// check for non-dashed value ahead in the args slice
-func missingValue(args []string, i int) bool {
-	return i+1 == len(args) || hasDashPrefix(args[i+1])
+// a lone dash is the conventional name for stdin, and so a value rather than a flag.
+func isOption(s string) bool {
+	return strings.HasPrefix(s, "-") && s != "-"
+}
+
+// -abc as shorthand for -a -b -c
+func isBundle(s string) bool {
+	return isOption(s) && !strings.HasPrefix(s, "--") && len(s) > 2
+}
+
+// check for non-dashed value ahead in the args slice. a dashed one is still a value if it parses as
+// one, as a negative number does for a flag which takes a number.
+func missingValue(args []string, i int, parses func(string) bool) bool {
+	if i+1 == len(args) {
+		return true
+	}
+	return isOption(args[i+1]) && (parses == nil || !parses(args[i+1]))
+}
+
+func isBool(f Flag) bool {
+	_, ok := f.(*Bool)
+	return ok
+}
+
+// flags which consume a value skip ahead in the args.
+func takesValue(f Flag) bool {
+	return f.MaybeIncrement(0) != 0
+}
+
+// find the option flag matching the given --long or -s name
+func findOption(flags []Flag, name string) Flag {
+	for j, f := range flags {
+		if !f.Positional() && f.Match(name, 0, j) {
+			return f
+		}
+	}
+	return nil
 }
 
 // expand incoming opts struct into a []Flag
@@ -194,35 +466,112 @@ func expandOpts(opts any) ([]Flag, error) {
 
 // internal for type safety testing
 func parseArgsInternal(help HelpError, args []string, opts any, flags []Flag) error {
+	// count of positional args seen, matched against the order of Arg and Glob fields:
+	p := 0
+	literal := false
 argloop:
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
-		for j, f := range flags {
-			if f.Match(arg, i, j) {
-				if f.IsHelp() {
-					// so Help.Val is true to avoid confusion:
+		if !literal && arg == TERMINATOR {
+			literal = true
+			continue
+		}
+
+		if literal || !isOption(arg) {
+			for j, f := range flags {
+				if f.Positional() && f.Match(arg, p, j) {
 					f.Set(arg)
+					p++
+					continue argloop
+				}
+			}
+			return help.HelpInvalidArg(arg)
+		}
+
+		// --key=value, or --bool=false
+		if name, val, ok := strings.Cut(arg, "="); ok {
+			f := findOption(flags, name)
+			if f == nil || f.IsHelp() || !takesValue(f) && !isBool(f) {
+				return help.HelpInvalidArg(arg)
+			}
+			if err := f.Set(val); err != nil {
+				return help.HelpInvalidValue(name, val)
+			}
+			help.mark(f)
+			continue
+		}
+
+		if f := findOption(flags, arg); f != nil {
+			if f.IsHelp() {
+				// so Help.Val is true to avoid confusion:
+				f.Set(arg)
+				return help.Help(opts)
+			}
+			if !f.Valid(args, i) {
+				return help.HelpMissingAssignment(arg)
+			}
+			i = f.MaybeIncrement(i)
+			if err := f.Set(args[i]); err != nil {
+				return help.HelpInvalidValue(arg, args[i])
+			}
+			help.mark(f)
+			continue
+		}
+
+		// -abc : a flag taking a value consumes the rest of the bundle or else the next arg
+		if isBundle(arg) {
+			for k, c := range arg[1:] {
+				name := "-" + string(c)
+				f := findOption(flags, name)
+				if f == nil {
+					return help.HelpInvalidArg(arg)
+				}
+				if f.IsHelp() {
+					f.Set(name)
 					return help.Help(opts)
 				}
-				if !f.Valid(args, i) {
-					return help.HelpMissingAssignment(arg)
+				if !takesValue(f) {
+					f.Set(name)
+					help.mark(f)
+					continue
+				}
+				val := arg[1+k+len(string(c)):]
+				if val == "" {
+					if !f.Valid(args, i) {
+						return help.HelpMissingAssignment(name)
+					}
+					i = f.MaybeIncrement(i)
+					val = args[i]
+				}
+				if err := f.Set(val); err != nil {
+					return help.HelpInvalidValue(name, val)
 				}
-				i = f.MaybeIncrement(i)
-				f.Set(args[i])
+				help.mark(f)
 				continue argloop
 			}
+			continue
 		}
-		// NOTE: if we didn't skip ahead, arg didn't match:
 		return help.HelpInvalidArg(arg)
 	}
 	return nil
 }
 
 // assign values of args to opts struct using Flag interface methods
+//
+// precedence is args > env > config > the value given in initOpts, for the flags taking defaults.
 func ParseArgs(help HelpError, args []string, opts any) error {
 	flags, err := expandOpts(opts)
 	if err != nil {
 		return err
 	}
+	help.origins = map[Flag]origin{}
+	if err := applyDefaults(help, opts, flags); err != nil {
+		// --help still works with a broken config or env, showing what it can:
+		var h HelpError
+		if perr := parseArgsInternal(help, args, opts, flags); errors.As(perr, &h) && h.Requested() {
+			return perr
+		}
+		return err
+	}
 	return parseArgsInternal(help, args, opts, flags)
 }
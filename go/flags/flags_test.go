@@ -1,7 +1,10 @@
 package flags
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,21 +26,21 @@ type options struct {
 
 func initOpts() options {
 	return options{
-		Arg{"", "midfix file descriptor"},
-		Arg{"", "tags to add to new file"},
-		String{"--source", "-s", "", "path to source list. reads from stdin if omitted."},
-		Bool{"--write", "-w", false, "write sorted list back to --key file"},
-		Bool{"--help", "-h", false, "show help"},
+		Arg{"", "midfix file descriptor", ""},
+		Arg{"", "tags to add to new file", ""},
+		String{"--source", "-s", "", "path to source list. reads from stdin if omitted.", "", nil},
+		Bool{"--write", "-w", false, "write sorted list back to --key file", nil},
+		Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
 func TestParseArgsInternal(t *testing.T) {
 	flags := []Flag{
-		&Arg{"", "midfix file descriptor"},
-		&Arg{"", "tags to add to new file"},
-		&String{"--source", "-s", "", "path to source list. reads from stdin if omitted."},
-		&Bool{"--write", "-w", false, "write sorted list back to --key file"},
-		&Bool{"--help", "-h", false, "show help"},
+		&Arg{"", "midfix file descriptor", ""},
+		&Arg{"", "tags to add to new file", ""},
+		&String{"--source", "-s", "", "path to source list. reads from stdin if omitted.", "", nil},
+		&Bool{"--write", "-w", false, "write sorted list back to --key file", nil},
+		&Bool{"--help", "-h", false, "show help", nil},
 	}
 	args := []string{"--source", "foo"}
 	parseArgsInternal(helpErr, args, initOpts(), flags)
@@ -124,3 +127,241 @@ func TestParseArgs(t *testing.T) {
 		})
 	}
 }
+
+type moreOptions struct {
+	Descriptor Arg
+	Files      Glob
+	Source     String
+	Exclude    Strings
+	Count      Int
+	Wait       Duration
+	Write      Bool
+	Verbose    Bool
+	Help       Bool
+}
+
+func initMoreOpts() moreOptions {
+	return moreOptions{
+		Arg{"", "midfix file descriptor", ""},
+		Glob{nil, "files", ""},
+		String{"--source", "-s", "", "path to source list", "", &Default{}},
+		Strings{"--exclude", "-x", nil, "exclude a tag. accepts multiple", "", nil},
+		Int{"--count", "-n", 0, "how many", "", &Default{}},
+		Duration{"--wait", "-t", 0, "how long", "", nil},
+		Bool{"--write", "-w", false, "write", &Default{}},
+		Bool{"--verbose", "-v", false, "verbose", &Default{"UM_LOUD", "loud"}},
+		Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+func TestParseArgsExtended(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  []string
+		check func(*testing.T, moreOptions)
+	}{
+		{"--flag=val", []string{"--source=foo"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, "foo", o.Source.Val)
+		}},
+		{"--flag=-val", []string{"--source=-foo"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, "-foo", o.Source.Val)
+		}},
+		{"-s=val", []string{"-s=foo"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, "foo", o.Source.Val)
+		}},
+		{"bundle", []string{"-wv"}, func(t *testing.T, o moreOptions) {
+			assert.True(t, o.Write.Val)
+			assert.True(t, o.Verbose.Val)
+		}},
+		{"bundle value", []string{"-wsfoo"}, func(t *testing.T, o moreOptions) {
+			assert.True(t, o.Write.Val)
+			assert.Equal(t, "foo", o.Source.Val)
+		}},
+		{"bundle next value", []string{"-ws", "foo"}, func(t *testing.T, o moreOptions) {
+			assert.True(t, o.Write.Val)
+			assert.Equal(t, "foo", o.Source.Val)
+		}},
+		{"terminator", []string{"-w", "--", "-foo", "--bar"}, func(t *testing.T, o moreOptions) {
+			assert.True(t, o.Write.Val)
+			assert.Equal(t, "-foo", o.Descriptor.Val)
+			assert.Equal(t, []string{"--bar"}, o.Files.Val)
+		}},
+		{"positional after flag", []string{"-s", "foo", "bar", "baz"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, "bar", o.Descriptor.Val)
+			assert.Equal(t, []string{"baz"}, o.Files.Val)
+		}},
+		{"stdin dash", []string{"-"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, "-", o.Descriptor.Val)
+		}},
+		{"negative int", []string{"--count", "-1"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, -1, o.Count.Val)
+		}},
+		{"bundle negative int", []string{"-wn", "-2"}, func(t *testing.T, o moreOptions) {
+			assert.True(t, o.Write.Val)
+			assert.Equal(t, -2, o.Count.Val)
+		}},
+		{"negative duration", []string{"-t", "-1s"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, -time.Second, o.Wait.Val)
+		}},
+		{"repeated", []string{"-x", "foo", "--exclude=bar", "-x", "baz"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, []string{"foo", "bar", "baz"}, o.Exclude.Val)
+		}},
+		{"int", []string{"-n", "3"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, 3, o.Count.Val)
+		}},
+		{"duration", []string{"--wait=1m30s"}, func(t *testing.T, o moreOptions) {
+			assert.Equal(t, 90*time.Second, o.Wait.Val)
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := initMoreOpts()
+			err := ParseArgs(helpErr, tc.args, &opts)
+			assert.NoError(t, err)
+			tc.check(t, opts)
+		})
+	}
+}
+
+func TestParseArgsExtendedErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"bad int", []string{"--count", "three"}, "um TEST: invalid value for --count: three"},
+		{"bad duration", []string{"-t=soon"}, "um TEST: invalid value for -t: soon"},
+		{"bool=val", []string{"--write=maybe"}, "um TEST: invalid value for --write: maybe"},
+		{"unknown in bundle", []string{"-wq"}, "um TEST: invalid argument: -wq"},
+		{"bundle missing value", []string{"-ws"}, "um TEST: -s needs a value assignment"},
+		{"unknown flag", []string{"--nope"}, "um TEST: invalid argument: --nope"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := initMoreOpts()
+			err := ParseArgs(helpErr, tc.args, &opts)
+			assert.EqualError(t, err, tc.want)
+		})
+	}
+}
+
+func TestParseArgsBundleHelp(t *testing.T) {
+	opts := initMoreOpts()
+	err := ParseArgs(helpErr, []string{"-wh"}, &opts)
+	assert.ErrorContains(t, err, "--exclude | -x   []string        exclude a tag. accepts multiple")
+	assert.ErrorContains(t, err, "--count | -n     int             how many")
+	assert.ErrorContains(t, err, "--wait | -t      time.Duration   how long")
+}
+
+func TestSpecs(t *testing.T) {
+	opts := initMoreOpts()
+	specs, err := Specs(&opts)
+	assert.NoError(t, err)
+	assert.Len(t, specs, 9)
+	assert.Equal(t, Spec{Name: "descriptor", Help: "midfix file descriptor", Positional: true}, specs[0])
+	assert.Equal(t, Spec{Name: "files", Help: "files", Positional: true, Repeated: true}, specs[1])
+	assert.Equal(t, Spec{Name: "exclude", Long: "--exclude", Short: "-x", Help: "exclude a tag. accepts multiple", Repeated: true, TakesValue: true}, specs[3])
+	assert.Equal(t, Spec{Name: "count", Long: "--count", Short: "-n", Help: "how many", TakesValue: true, Defaults: true}, specs[4])
+	assert.Equal(t, Spec{Name: "write", Long: "--write", Short: "-w", Help: "write", Defaults: true}, specs[6])
+	assert.False(t, specs[5].Defaults)
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "UM_CAT_BASE", EnvName("cat", "--base"))
+	assert.Equal(t, "UM_CAT_KEEP_HEADER", EnvName("cat", "--keep-header"))
+	assert.Equal(t, "keep-header", ConfigKey("--keep-header"))
+}
+
+func TestParseArgsDefaults(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, os.WriteFile(conf, []byte("[TEST]\nsource = \"conf\"\ncount = 2\nwrite = true\nloud = true\n"), 0664))
+	t.Setenv("UM_CONFIG", conf)
+
+	cases := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		source string
+		count  int
+		write  bool
+	}{
+		{"config", nil, nil, "conf", 2, true},
+		{"env over config", map[string]string{"UM_TEST_SOURCE": "env", "UM_TEST_WRITE": "false"}, nil, "env", 2, false},
+		{"args over env", map[string]string{"UM_TEST_SOURCE": "env"}, []string{"--source", "arg", "-n", "3"}, "arg", 3, true},
+		{"bool off over config", nil, []string{"--write=false"}, "conf", 2, false},
+		{"bool off over env", map[string]string{"UM_TEST_WRITE": "true"}, []string{"-w=0"}, "conf", 2, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			opts := initMoreOpts()
+			err := ParseArgs(helpErr, tc.args, &opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.source, opts.Source.Val)
+			assert.Equal(t, tc.count, opts.Count.Val)
+			assert.Equal(t, tc.write, opts.Write.Val)
+			assert.True(t, opts.Verbose.Val)
+		})
+	}
+}
+
+func TestParseArgsDefaultsDeclared(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, os.WriteFile(conf, []byte("[TEST]\nverbose = true\n"), 0664))
+	t.Setenv("UM_CONFIG", conf)
+	// a declared name replaces the derived one:
+	t.Setenv("UM_TEST_VERBOSE", "true")
+	opts := initMoreOpts()
+	assert.NoError(t, ParseArgs(helpErr, nil, &opts))
+	assert.False(t, opts.Verbose.Val)
+
+	t.Setenv("UM_LOUD", "true")
+	opts = initMoreOpts()
+	err := ParseArgs(helpErr, []string{"--help"}, &opts)
+	assert.True(t, opts.Verbose.Val)
+	assert.Regexp(t, `--verbose \| -v +bool +verbose += true +env UM_LOUD\n`, err.Error())
+	assert.Regexp(t, `\n--source +UM_TEST_SOURCE +\[TEST\] source\n`, err.Error())
+	assert.Regexp(t, `\n--verbose +UM_LOUD +\[TEST\] loud\n`, err.Error())
+}
+
+func TestParseArgsDefaultsOptIn(t *testing.T) {
+	// a flag without the tag takes no default:
+	t.Setenv("UM_TEST_WAIT", "1s")
+	opts := initMoreOpts()
+	assert.NoError(t, ParseArgs(helpErr, nil, &opts))
+	assert.Equal(t, time.Duration(0), opts.Wait.Val)
+
+	assert.EqualError(t, ParseArgs(helpErr, []string{"--help=true"}, &opts), "um TEST: invalid argument: --help=true")
+
+	// and the config isn't read at all without one:
+	t.Setenv("UM_CONFIG", filepath.Join(t.TempDir(), "missing.toml"))
+	plain := initOpts()
+	assert.NoError(t, ParseArgs(helpErr, []string{"-w"}, &plain))
+}
+
+func TestParseArgsDefaultsHelp(t *testing.T) {
+	t.Setenv("UM_CONFIG", filepath.Join(t.TempDir(), "missing.toml"))
+	opts := initMoreOpts()
+	err := ParseArgs(helpErr, nil, &opts)
+	assert.ErrorContains(t, err, "error opening config")
+	// but --help still works:
+	err = ParseArgs(helpErr, []string{"--help"}, &opts)
+	assert.ErrorContains(t, err, "--write | -w")
+	assert.True(t, err.(HelpError).Requested())
+
+	t.Setenv("UM_CONFIG", "")
+	t.Setenv("UM_TEST_SOURCE", "env")
+	t.Setenv("UM_TEST_WRITE", "maybe")
+	err = ParseArgs(helpErr, nil, &opts)
+	assert.EqualError(t, err, "um TEST: invalid value for UM_TEST_WRITE: maybe")
+
+	t.Setenv("UM_TEST_WRITE", "1")
+	opts = initMoreOpts()
+	err = ParseArgs(helpErr, []string{"-n", "4", "--help"}, &opts)
+	assert.Regexp(t, `--source \| -s +string +path to source list += "env" +env UM_TEST_SOURCE\n`, err.Error())
+	assert.Regexp(t, `--count \| -n +int +how many += 4 +cli\n`, err.Error())
+	assert.Regexp(t, `--write \| -w +bool +write += true +env UM_TEST_WRITE\n`, err.Error())
+	assert.Regexp(t, `--wait \| -t +time.Duration +how long += 0s +default\n`, err.Error())
+}
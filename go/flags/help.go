@@ -8,12 +8,17 @@ import (
 	"text/tabwriter"
 
 	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
 )
 
 type HelpError struct {
 	sub     cmd.Subcommand
 	summary string
 	message string
+	// where each flag's value came from, for --help:
+	origins map[Flag]origin
+	// --help itself, as opposed to a usage error:
+	requested bool
 }
 
 func (h HelpError) Error() string {
@@ -23,7 +28,35 @@ func (h HelpError) Error() string {
 // TODO: is this odd? Constructing an error at the callsite and passing it down and back up?
 // but how else should I fill out its fields to be reused later?
 func NewHelpError(sub cmd.Subcommand, summary string) HelpError {
-	return HelpError{sub, summary, ""}
+	return HelpError{sub, summary, "", nil, false}
+}
+
+// whether --help was asked for. otherwise this is a usage error.
+func (h HelpError) Requested() bool {
+	return h.requested
+}
+
+// so that main can map a usage error to its exit status with errors.Is
+func (h HelpError) Is(target error) bool {
+	return target == cmd.ErrUsage && !h.requested
+}
+
+// record that a flag was set on the commandline
+func (h HelpError) mark(f Flag) {
+	if h.origins != nil {
+		h.origins[f] = origin{CLI, ""}
+	}
+}
+
+func (h HelpError) origin(field reflect.Value) origin {
+	if field.CanAddr() {
+		if f, ok := field.Addr().Interface().(Flag); ok {
+			if o, ok := h.origins[f]; ok {
+				return o
+			}
+		}
+	}
+	return origin{DEFAULT, ""}
 }
 
 func (h HelpError) HelpRequired(long string) error {
@@ -37,6 +70,16 @@ func (h HelpError) HelpInvalidArg(arg string) error {
 
 }
 
+func (h HelpError) HelpExclusive(a, b string) error {
+	h.message = fmt.Sprintf("um %s: %s and %s are exclusive", h.sub, a, b)
+	return h
+}
+
+func (h HelpError) HelpInvalidValue(arg, val string) error {
+	h.message = fmt.Sprintf("um %s: invalid value for %s: %s", h.sub, arg, val)
+	return h
+}
+
 func (h HelpError) HelpMissingAssignment(arg string) error {
 	h.message = fmt.Sprintf("um %s: %s needs a value assignment", h.sub, arg)
 	return h
@@ -67,16 +110,26 @@ func (h HelpError) Help(opts any) error {
 				strings.ToLower(tField.Name),
 				vField.FieldByName("Val").Type(),
 				vField.FieldByName("Help"))
-		case String, Bool:
+		case String, Strings, Int, Duration, Bool:
 			if tField.Name != "Help" {
 				positional += fmt.Sprintf(" [%s]", vField.FieldByName("Long"))
 			}
-			fmt.Fprintf(w, "%v | %v\t%v\t%v\n",
+			fmt.Fprintf(w, "%v | %v\t%v\t%v",
 				vField.FieldByName("Long"),
 				vField.FieldByName("Short"),
 				vField.FieldByName("Val").Type(),
 				vField.FieldByName("Help"),
 			)
+			// the effective value and where it came from:
+			if tField.Name != "Help" {
+				val := vField.FieldByName("Val")
+				if val.Kind() == reflect.String {
+					fmt.Fprintf(w, "\t= %q\t%s", val.String(), h.origin(vField))
+				} else {
+					fmt.Fprintf(w, "\t= %v\t%s", val.Interface(), h.origin(vField))
+				}
+			}
+			fmt.Fprintln(w)
 		default:
 			return ParseError{fmt.Sprintf("Help did not recognize the Flag: %v", vField.Type())}
 
@@ -88,6 +141,25 @@ func (h HelpError) Help(opts any) error {
 	if err := w.Flush(); err != nil {
 		return err
 	}
+	specs, err := Specs(opts)
+	if err != nil {
+		return err
+	}
+	if dd := defaulted(specs); len(dd) > 0 {
+		conf := config.Path()
+		if conf == "" {
+			conf = config.FILE
+		}
+		fmt.Fprintf(buf, "\ndefaults from env, then config in %s:\n", conf)
+		w = tabwriter.NewWriter(buf, 0, 0, 3, ' ', 0)
+		for _, s := range dd {
+			fmt.Fprintf(w, "%s\t%s\t[%s] %s\n", s.Long, s.EnvName(h.sub), h.sub, s.ConfigKey())
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
 	h.message = buf.String()
+	h.requested = true
 	return h
 }
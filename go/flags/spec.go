@@ -0,0 +1,93 @@
+package flags
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+)
+
+// separates the words a flag's Complete takes, as
+//
+//	flags.String{"--merge", "-m", "", "...", "append|number|date", nil}
+//
+// otherwise Complete names a kind of value, as "um", "tags" or "none". empty offers files.
+const COMPLETE_SEP = "|"
+
+// the reflected shape of a single flag, for consumers other than --help such as um completion.
+type Spec struct {
+	// lowercased field name, as shown for positionals in --help
+	Name       string
+	Long       string
+	Short      string
+	Help       string
+	Positional bool
+	Repeated   bool
+	TakesValue bool
+	// from Complete: the kind of value, or else the words it takes. both empty without one.
+	Complete string
+	Words    []string
+	// whether it takes a default from the env and config, and the env var and config key as declared.
+	// empty ones are derived, as by Spec.EnvName and Spec.ConfigKey:
+	Defaults bool
+	Env      string
+	Key      string
+}
+
+// the env var a flag takes its default from: as declared, or else derived by EnvName.
+func (s Spec) EnvName(sub cmd.Subcommand) string {
+	if s.Env != "" {
+		return s.Env
+	}
+	return EnvName(sub, s.Long)
+}
+
+// the config key a flag takes its default from, under the [sub] section: as declared, or else derived
+// by ConfigKey.
+func (s Spec) ConfigKey() string {
+	if s.Key != "" {
+		return s.Key
+	}
+	return ConfigKey(s.Long)
+}
+
+// describe each field of the incoming opts struct.
+//
+// WARN: incoming opts must be a pointer, as for ParseArgs.
+func Specs(opts any) ([]Spec, error) {
+	flags, err := expandOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(opts).Elem()
+	t := v.Type()
+
+	specs := make([]Spec, 0, len(flags))
+	for j, f := range flags {
+		field := v.Field(j)
+		s := Spec{
+			Name:       strings.ToLower(t.Field(j).Name),
+			Help:       field.FieldByName("Help").String(),
+			Positional: f.Positional(),
+			TakesValue: takesValue(f),
+		}
+		if !s.Positional {
+			s.Long = field.FieldByName("Long").String()
+			s.Short = field.FieldByName("Short").String()
+		}
+		switch f.(type) {
+		case *Glob, *Strings:
+			s.Repeated = true
+		}
+		if d := f.Defaults(); d != nil && !f.IsHelp() {
+			s.Defaults, s.Env, s.Key = true, d.Env, d.Key
+		}
+		if c := f.Completes(); strings.Contains(c, COMPLETE_SEP) {
+			s.Words = strings.Split(c, COMPLETE_SEP)
+		} else {
+			s.Complete = c
+		}
+		specs = append(specs, s)
+	}
+	return specs, nil
+}
@@ -0,0 +1,192 @@
+package graph
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Graph
+	SUMMARY = "export the tag co-occurrence graph as DOT, GraphML or JSON"
+)
+
+type Format string
+
+const (
+	DOT     Format = "dot"
+	GRAPHML Format = "graphml"
+	JSON    Format = "json"
+)
+
+// what a node stands for, and what an edge joins.
+type Kind string
+
+const (
+	TAG  Kind = "tag"
+	FILE Kind = "file"
+	// tag to tag, weighted by the files they share:
+	COOCCURS Kind = "cooccurs"
+	// tag to file:
+	TAGGED Kind = "tagged"
+	// file to file, the only directed edge:
+	LINKS Kind = "links"
+)
+
+type options struct {
+	Query        flags.Arg
+	Date         flags.String
+	Files        flags.Bool
+	Links        flags.Bool
+	Format       flags.String
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Arg{"", "tag query as for um tag: restricts the graph to the matching files", "tags"},
+		flags.String{"--date", "-d", "", "date range in ISO 8601: YYYY.MM.DD[-YYYY.MM.DD]", "none", nil},
+		flags.Bool{"--files", "-F", false, "add file nodes, with an edge from each of their tags", &flags.Default{}},
+		flags.Bool{"--links", "-l", false, "add file nodes, with an edge for each file link between them", &flags.Default{}},
+		flags.String{"--format", "-f", string(DOT), "dot | graphml | json", "dot|graphml|json", &flags.Default{}},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+type Node struct {
+	// prefixed by kind, since a tag and a file may share a name:
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Kind  Kind   `json:"kind"`
+	// files for a tag, tags for a file:
+	Weight int `json:"weight"`
+}
+
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   Kind   `json:"kind"`
+	Weight int    `json:"weight"`
+}
+
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+func id(k Kind, name string) string {
+	return string(k) + ":" + name
+}
+
+// builds the graph of the matching files. nodes and edges are sorted, so that the output is stable.
+func graph(ix *tag.Index, files tag.Bitmap, withFiles, withLinks bool) Graph {
+	g := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	counts := map[string]int{}
+	names := ix.Set(files)
+	for _, e := range ix.Entries(files) {
+		for _, t := range e.Tags() {
+			counts[t]++
+		}
+		if !withFiles && !withLinks {
+			continue
+		}
+		g.Nodes = append(g.Nodes, Node{id(FILE, e.Filename()), e.Filename(), FILE, len(e.Tags())})
+		if withFiles {
+			for _, t := range e.Tags() {
+				g.Edges = append(g.Edges, Edge{id(TAG, t), id(FILE, e.Filename()), TAGGED, 1})
+			}
+		}
+		if withLinks {
+			for _, l := range e.Links() {
+				// only links within the graph:
+				if names[l] && l != e.Filename() {
+					g.Edges = append(g.Edges, Edge{id(FILE, e.Filename()), id(FILE, l), LINKS, 1})
+				}
+			}
+		}
+	}
+	for t, n := range counts {
+		g.Nodes = append(g.Nodes, Node{id(TAG, t), t, TAG, n})
+	}
+
+	for a, others := range tag.MakeAdjacencies(ix, files) {
+		for b, shared := range others {
+			// each pair is in the map both ways round:
+			if a < b {
+				g.Edges = append(g.Edges, Edge{id(TAG, a), id(TAG, b), COOCCURS, shared.Len()})
+			}
+		}
+	}
+
+	slices.SortFunc(g.Nodes, func(a, b Node) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	slices.SortFunc(g.Edges, func(a, b Edge) int {
+		return cmp.Or(
+			strings.Compare(string(a.Kind), string(b.Kind)),
+			strings.Compare(a.Source, b.Source),
+			strings.Compare(a.Target, b.Target),
+		)
+	})
+	return g
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
+	// BORK: by hand for now:
+	if !slices.Contains([]Format{DOT, GRAPHML, JSON}, Format(opts.Format.Val)) {
+		return help.HelpInvalidValue(opts.Format.Long, opts.Format.Val)
+	}
+
+	entries, err := tag.EntriesGlobOrStdin(stdin, last.GLOB, pipe.Sep(opts.Null.Val))
+	if err != nil {
+		return err
+	}
+	if opts.Date.IsSet() {
+		entries, err = tag.DateRange(entries, opts.Date.Val)
+		if err != nil {
+			return err
+		}
+	}
+	ix := tag.NewIndex(entries)
+	files, err := ix.Match(opts.Query.Val, false)
+	if err != nil {
+		return err
+	}
+	g := graph(ix, files, opts.Files.Val, opts.Links.Val)
+
+	switch Format(opts.Format.Val) {
+	case GRAPHML:
+		return writeGraphML(stdout, g)
+	case JSON:
+		return writeJSON(stdout, g)
+	}
+	return writeDOT(stdout, g)
+}
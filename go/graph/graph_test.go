@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brtholomy/um/go/tag"
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_PATTERN string = "../tag/testdata/*.md"
+
+func testEntries(t *testing.T, pattern string) []tag.Entry {
+	entries, err := tag.EntriesGlobOrStdin(nil, pattern, "\n")
+	assert.NoError(t, err)
+	return entries
+}
+
+func testGraph(t *testing.T, entries []tag.Entry, query string, withFiles, withLinks bool) Graph {
+	ix := tag.NewIndex(entries)
+	files, err := ix.Match(query, false)
+	assert.NoError(t, err)
+	return graph(ix, files, withFiles, withLinks)
+}
+
+func TestGraph(t *testing.T) {
+	entries := testEntries(t, TEST_PATTERN)
+	g := testGraph(t, entries, "", false, false)
+	assert.Equal(t, []Node{
+		{"tag:bar", "bar", TAG, 3},
+		{"tag:diff", "diff", TAG, 1},
+		{"tag:foo", "foo", TAG, 1},
+		{"tag:science", "science", TAG, 3},
+	}, g.Nodes)
+	assert.Equal(t, []Edge{
+		{"tag:bar", "tag:foo", COOCCURS, 1},
+		{"tag:bar", "tag:science", COOCCURS, 2},
+	}, g.Edges)
+
+	// the query restricts the files, and so the tags and weights:
+	g = testGraph(t, entries, "foo", true, false)
+	assert.Equal(t, []Node{
+		{"file:01.foo.md", "01.foo.md", FILE, 2},
+		{"tag:bar", "bar", TAG, 1},
+		{"tag:foo", "foo", TAG, 1},
+	}, g.Nodes)
+	assert.Equal(t, []Edge{
+		{"tag:bar", "tag:foo", COOCCURS, 1},
+		{"tag:bar", "file:01.foo.md", TAGGED, 1},
+		{"tag:foo", "file:01.foo.md", TAGGED, 1},
+	}, g.Edges)
+}
+
+func TestGraphLinks(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string]string{
+		"01.a.md": "# 01.a.md\n+ x\n\nSee:\n\n---\n\n02.b.md\n03.c.md\n99.gone.md\n01.a.md\n",
+		"02.b.md": "# 02.b.md\n+ x\n\n1. a list item.\n2. not a link.md\n03.c.md is not alone\n",
+		"03.c.md": "# 03.c.md\n+ y\n\n02.b.md\n02.b.md\n",
+	}
+	for f, c := range contents {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte(c), 0664))
+	}
+	entries := testEntries(t, filepath.Join(dir, "*.md"))
+	g := testGraph(t, entries, "", false, true)
+	assert.Equal(t, []Edge{
+		{"file:01.a.md", "file:02.b.md", LINKS, 1},
+		{"file:01.a.md", "file:03.c.md", LINKS, 1},
+		{"file:03.c.md", "file:02.b.md", LINKS, 1},
+	}, g.Edges)
+	// no tagged edges without --files, but the file nodes are there for the links:
+	assert.Len(t, g.Nodes, 5)
+}
+
+func TestWrite(t *testing.T) {
+	entries := testEntries(t, TEST_PATTERN)
+	g := testGraph(t, entries, "foo", true, false)
+
+	w := bytes.Buffer{}
+	assert.NoError(t, writeDOT(&w, g))
+	assert.Contains(t, w.String(), "graph um {\n")
+	assert.Contains(t, w.String(), `  "tag:bar" -- "tag:foo" [kind=cooccurs, weight=1];`)
+	assert.Contains(t, w.String(), `  "file:01.foo.md" [label="01.foo.md", kind=file, weight=2];`)
+
+	w.Reset()
+	assert.NoError(t, writeJSON(&w, g))
+	got := Graph{}
+	assert.NoError(t, json.Unmarshal(w.Bytes(), &got))
+	assert.Equal(t, g, got)
+
+	w.Reset()
+	assert.NoError(t, writeGraphML(&w, g))
+	doc := graphml{}
+	assert.NoError(t, xml.Unmarshal(w.Bytes(), &doc))
+	assert.Len(t, doc.Graph.Nodes, len(g.Nodes))
+	assert.Len(t, doc.Graph.Edges, len(g.Edges))
+	assert.Equal(t, "undirected", doc.Graph.EdgeDefault)
+}
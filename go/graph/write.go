@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// an undirected graph, with dir=forward on the directed link edges.
+func writeDOT(w io.Writer, g Graph) error {
+	fmt.Fprintln(w, "graph um {")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "  %s [label=%s, kind=%s, weight=%d];\n", strconv.Quote(n.ID), strconv.Quote(n.Label), n.Kind, n.Weight)
+	}
+	for _, e := range g.Edges {
+		dir := ""
+		if e.Kind == LINKS {
+			dir = ", dir=forward"
+		}
+		fmt.Fprintf(w, "  %s -- %s [kind=%s, weight=%d%s];\n", strconv.Quote(e.Source), strconv.Quote(e.Target), e.Kind, e.Weight, dir)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type graphml struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphmlNode `xml:"node"`
+		Edges       []graphmlEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed bool          `xml:"directed,attr,omitempty"`
+	Data     []graphmlData `xml:"data"`
+}
+
+func writeGraphML(w io.Writer, g Graph) error {
+	doc := graphml{XMLNS: "http://graphml.graphdrawing.org/xmlns"}
+	doc.Keys = []graphmlKey{
+		{"label", "node", "label", "string"},
+		{"kind", "all", "kind", "string"},
+		{"weight", "all", "weight", "int"},
+	}
+	doc.Graph.ID = "um"
+	doc.Graph.EdgeDefault = "undirected"
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{n.ID, []graphmlData{
+			{"label", n.Label},
+			{"kind", string(n.Kind)},
+			{"weight", strconv.Itoa(n.Weight)},
+		}})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{e.Source, e.Target, e.Kind == LINKS, []graphmlData{
+			{"kind", string(e.Kind)},
+			{"weight", strconv.Itoa(e.Weight)},
+		}})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func writeJSON(w io.Writer, g Graph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
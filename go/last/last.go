@@ -1,9 +1,9 @@
 package last
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"path/filepath"
 
 	"github.com/brtholomy/um/go/cmd"
@@ -18,7 +18,7 @@ const (
 const (
 	GLOB = "[0-9]*.md"
 	// we only care about the number group:
-	NOT_FOUND_MSG = "um files not found: " + GLOB
+	NOT_FOUND_MSG = "um files: " + GLOB
 )
 
 type options struct {
@@ -27,10 +27,20 @@ type options struct {
 
 func initOpts() options {
 	return options{
-		flags.Bool{"--help", "-h", false, "show help"},
+		flags.Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
 // get the lexical last file from GLOB
 func GlobLast(glob string) (string, error) {
 	// NOTE: filepath.Glob is more reliable than a manual ls call:
@@ -39,26 +49,22 @@ func GlobLast(glob string) (string, error) {
 		return "", err
 	}
 	if len(filelist) == 0 {
-		return "", errors.New(NOT_FOUND_MSG)
+		return "", fmt.Errorf("%w: %s", cmd.ErrNotFound, NOT_FOUND_MSG)
 	}
 	return filelist[len(filelist)-1], nil
 }
 
-func Last(args []string) {
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	opts := initOpts()
 	help := flags.NewHelpError(CMD, SUMMARY)
 	if err := flags.ParseArgs(help, args, &opts); err != nil {
-		if errors.As(err, &help) {
-			fmt.Println(help)
-			return
-		}
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
 	}
 
 	s, err := GlobLast(GLOB)
 	if err != nil {
-		log.Fatalf("um %s: %v", cmd.Last, err)
+		return err
 	}
-	// send to stdout, not stderr as is default for log.Print:
-	fmt.Println(s)
+	fmt.Fprintln(stdout, s)
+	return nil
 }
@@ -1,9 +1,9 @@
 package mv
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"strings"
 
@@ -27,12 +27,22 @@ type options struct {
 
 func initOpts() options {
 	return options{
-		flags.Arg{"", "old filename"},
-		flags.Arg{"", "new descriptor"},
-		flags.Bool{"--help", "-h", false, "show help"},
+		flags.Arg{"", "old filename", "zettel"},
+		flags.Arg{"", "new descriptor", "none"},
+		flags.Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
 // generates new filename and its content with updated H1 header
 func newNameAndContent(olds string, opts options) (name string, content string, err error) {
 	// name and title
@@ -88,18 +98,11 @@ func mv(opts options) error {
 	return nil
 }
 
-func Mv(args []string) {
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	opts := initOpts()
 	help := flags.NewHelpError(CMD, SUMMARY)
 	if err := flags.ParseArgs(help, args, &opts); err != nil {
-		if errors.As(err, &help) {
-			fmt.Println(help)
-			return
-		}
-		log.Fatalf("um %s: %s", CMD, err)
-	}
-	err := mv(opts)
-	if err != nil {
-		log.Fatalf("um %s: %v", CMD, err)
+		return err
 	}
+	return mv(opts)
 }
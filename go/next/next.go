@@ -1,9 +1,9 @@
 package next
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -31,19 +31,29 @@ type options struct {
 
 func initOpts() options {
 	return options{
-		flags.Arg{"", "midfix file descriptor"},
-		flags.Arg{"", "tags to add to new file"},
-		flags.Bool{"--help", "-h", false, "show help"},
+		flags.Arg{"", "midfix file descriptor", "none"},
+		flags.Arg{"", "tags to add to new file", "tags"},
+		flags.Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
 // takes the complete last file string
 // returns the number as string
 func NumFromLast(l string) (string, error) {
 	res := fileRegexp.FindStringSubmatch(l)
 	num := ""
 	if len(res) < 2 {
-		return num, fmt.Errorf("%s: %s", NEXT_NUM_ERROR, l)
+		return num, fmt.Errorf("%w: %s: %s", cmd.ErrParse, NEXT_NUM_ERROR, l)
 	}
 	num = res[1]
 	return num, nil
@@ -82,7 +92,7 @@ func next(last string, desc string) (string, error) {
 // `um tag` logic in Elisp.
 //
 // So I've got Go and and an ancient beloved Lisp machine trying to live together.
-func emacsNext(f string, tags string) error {
+func emacsNext(stderr io.Writer, f string, tags string) error {
 	quotedargs := fmt.Sprintf(`"%s"`, f)
 	if tags != "" {
 		quotedargs = fmt.Sprintf(`%s "%s"`, quotedargs, tags)
@@ -94,29 +104,23 @@ func emacsNext(f string, tags string) error {
 	}
 	// NOTE: sends to stderr : is this what we want?
 	// if we decide to pipe the filename out, yes.
-	log.Print(string(out))
+	fmt.Fprint(stderr, string(out))
 	return nil
 }
 
-func Next(args []string) {
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	opts := initOpts()
 	help := flags.NewHelpError(CMD, SUMMARY)
 	if err := flags.ParseArgs(help, args, &opts); err != nil {
-		if errors.As(err, &help) {
-			fmt.Println(help)
-			return
-		}
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
 	}
 	l, err := last.GlobLast(last.GLOB)
 	if err != nil {
-		log.Fatalf("um %s: %v", CMD, err)
+		return err
 	}
 	filename, err := next(l, opts.Descriptor.Val)
 	if err != nil {
-		log.Fatalf("um %s: %v", CMD, err)
-	}
-	if err := emacsNext(filename, opts.Tags.Val); err != nil {
-		log.Fatalf("um %s: %v", CMD, err)
+		return err
 	}
+	return emacsNext(stderr, filename, opts.Tags.Val)
 }
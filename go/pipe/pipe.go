@@ -1,73 +1,346 @@
 package pipe
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"iter"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/brtholomy/um/go/cmd"
 )
 
 const Newline string = "\n"
 
-func isStdinLoaded() bool {
-	stat, _ := os.Stdin.Stat()
-	return (stat.Mode() & os.ModeCharDevice) == 0
+// separates a filelist from find -print0 or xargs -0.
+const Null string = "\x00"
+
+// an explicit filelist argument for reading stdin, instead of guessing whether it's loaded.
+const STDIN string = "-"
+
+var ErrNoStdin = errors.New("stdin not loaded")
+
+var errExplicitStdin = fmt.Errorf("%w: %s given but there is no stdin", cmd.ErrUsage, STDIN)
+
+// how long a guess at stdin waits for it to write anything, unless a --stdin-timeout says otherwise.
+// an IDE runner or a cron wrapper may leave stdin open without ever writing to it, and we would rather
+// go on without a filelist than hang. the env var sets it for every command:
+const (
+	STDIN_TIMEOUT     = time.Second
+	STDIN_TIMEOUT_ENV = "UM_STDIN_TIMEOUT"
+)
+
+// stdin which a guess waits for only so long, as given by a --stdin-timeout. an explicit - reads it
+// however long it takes.
+type Timed struct {
+	io.Reader
+	Timeout time.Duration
 }
 
-func GetStdin() ([]string, error) {
-	if !isStdinLoaded() {
-		return nil, errors.New("stdin not loaded")
+// stdin as guessed at by a command with a --stdin-timeout. none stays none.
+func WithTimeout(stdin io.Reader, d time.Duration) io.Reader {
+	if stdin == nil {
+		return nil
 	}
-	dat, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		log.Fatal(err)
+	return Timed{stdin, d}
+}
+
+// marks a line in a filelist which is not a filename.
+const Comment string = "# "
+
+// the separator for a filelist, from a --null flag.
+func Sep(null bool) string {
+	if null {
+		return Null
 	}
-	// TODO: there's got to be a better way:
-	s := strings.TrimSuffix(string(dat), Newline)
-	return strings.Split(s, Newline), nil
+	return Newline
 }
 
-// reads files from stdin if present, otherwise just returns the glob:
-func GlobOrStdin(glob []string) ([]string, error) {
-	filelist, err := GetStdin()
-	if err != nil {
-		if glob == nil || len(glob) == 0 {
-			return nil, fmt.Errorf("glob is empty")
+// normalizes one entry of a filelist, false if it isn't a filename at all. a newline list has CRLF
+// endings and surrounding space trimmed, and blank lines and comments dropped. a NUL list is taken
+// literally, since such filenames may hold anything.
+func clean(l string, sep string) (string, bool) {
+	if sep == Newline {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, Comment) {
+			return "", false
+		}
+	}
+	return l, l != ""
+}
+
+// splits a filelist into filenames, as normalized by clean.
+func Split(s string, sep string) []string {
+	filelist := []string{}
+	for _, l := range strings.Split(s, sep) {
+		if l, ok := clean(l, sep); ok {
+			filelist = append(filelist, l)
+		}
+	}
+	return filelist
+}
+
+// a bufio.SplitFunc for entries ending in sep, or at EOF.
+func splitOn(sep byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, sep); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
 		}
-		return glob, nil
+		return 0, nil, nil
+	}
+}
+
+// yields the filenames of a filelist as they are read, as normalized by clean.
+func Lines(r io.Reader, sep string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Split(splitOn(sep[0]))
+		for scanner.Scan() {
+			l, ok := clean(scanner.Text(), sep)
+			if ok && !yield(l, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield("", fmt.Errorf("%w: error reading filelist: %w", cmd.ErrIO, err))
+		}
+	}
+}
+
+func collect(seq iter.Seq2[string, error]) ([]string, error) {
+	filelist := []string{}
+	for f, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		filelist = append(filelist, f)
 	}
 	return filelist, nil
 }
 
-// reads files from stdin if present, otherwise reads content from files in glob and assembles into a list.
-func FileListFromGlobOrStdin(glob []string) ([]string, error) {
-	filelist, err := GetStdin()
+// stdin ready to be read, if anything is there. a redirected file is read as it is. ErrNoStdin for a
+// terminal or /dev/null, or a nil reader, which is how in-process callers say there is none. anything
+// else, such as a pipe or the socket an IDE runner may leave open, is only a guess: a Timed stdin
+// which writes nothing within its timeout is ErrNoStdin, and one without a timeout is read until EOF.
+func openStdin(stdin io.Reader) (io.Reader, error) {
+	if stdin == nil {
+		return nil, ErrNoStdin
+	}
+	var timeout time.Duration
+	if t, ok := stdin.(Timed); ok {
+		stdin, timeout = t.Reader, t.Timeout
+	}
+	if f, ok := stdin.(*os.File); ok {
+		stat, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("%w: error reading stdin: %w", cmd.ErrIO, err)
+		}
+		switch mode := stat.Mode(); {
+		case mode&os.ModeCharDevice != 0:
+			return nil, ErrNoStdin
+		case mode.IsRegular():
+			return f, nil
+		}
+	}
+	if timeout <= 0 {
+		return stdin, nil
+	}
+	return waitStdin(stdin, timeout)
+}
+
+// waits up to timeout for stdin to write anything at all. once it has, the rest is read however long
+// it takes.
+func waitStdin(stdin io.Reader, timeout time.Duration) (io.Reader, error) {
+	br := bufio.NewReader(stdin)
+	ready := make(chan error, 1)
+	go func() {
+		_, err := br.Peek(1)
+		ready <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-ready:
+		// closed straight away, as under cron:
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoStdin
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: error reading stdin: %w", cmd.ErrIO, err)
+		}
+		return br, nil
+	case <-timer.C:
+		// NOTE: this unblocks the read where the file allows a deadline. otherwise it stays blocked
+		// until the writer closes or um exits, which is harmless since stdin is never read again:
+		if f, ok := stdin.(*os.File); ok {
+			f.SetReadDeadline(time.Now())
+		}
+		return nil, ErrNoStdin
+	}
+}
+
+// reads the filelist on stdin, if there's anything there. ErrNoStdin as for openStdin, or when it
+// holds no filenames, as from a closed pipe.
+func GetStdin(stdin io.Reader, sep string) ([]string, error) {
+	r, err := openStdin(stdin)
 	if err != nil {
-		for _, f := range glob {
-			// TODO: don't see a neater way of wrapping these two when we depend on the non-nil in this block:
-			// if i just return FileListSplit(), we lose the first err
-			fl, err2 := FileListSplit(f)
-			if err2 != nil {
-				return nil, fmt.Errorf("%w: %w", err, err2)
-			}
-			filelist = append(filelist, fl...)
+		return nil, err
+	}
+	filelist, err := ReadStdin(r, sep)
+	if err == nil && len(filelist) == 0 {
+		return nil, ErrNoStdin
+	}
+	return filelist, err
+}
+
+// reads the filelist on stdin unconditionally, as for an explicit -.
+func ReadStdin(stdin io.Reader, sep string) ([]string, error) {
+	if stdin == nil {
+		return nil, errExplicitStdin
+	}
+	return collect(Lines(stdin, sep))
+}
+
+// reads files from the glob if given, otherwise from stdin. a - in the glob reads stdin in its place.
+func GlobOrStdin(stdin io.Reader, glob []string, sep string) ([]string, error) {
+	if len(glob) == 0 {
+		filelist, err := GetStdin(stdin, sep)
+		if errors.Is(err, ErrNoStdin) {
+			return nil, fmt.Errorf("%w: glob is empty", cmd.ErrUsage)
+		}
+		return filelist, err
+	}
+	filelist := []string{}
+	for _, f := range glob {
+		if f != STDIN {
+			filelist = append(filelist, f)
+			continue
+		}
+		fl, err := ReadStdin(stdin, sep)
+		if err != nil {
+			return nil, err
 		}
-		return filelist, nil
+		filelist = append(filelist, fl...)
 	}
 	return filelist, nil
 }
 
-// opens the given filename and splits into lines:
+// reads content from the filelists in glob if given and assembles them into one list, otherwise reads
+// files from stdin. a - in the glob reads files from stdin in its place.
+func FileListFromGlobOrStdin(stdin io.Reader, glob []string, sep string) ([]string, error) {
+	return collect(FileListSeq(stdin, glob, sep))
+}
+
+// FileListFromGlobOrStdin, yielding each file as it is read so that a long filelist is never held in
+// memory, and the first file can be used before the last is read.
+func FileListSeq(stdin io.Reader, glob []string, sep string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if len(glob) == 0 {
+			r, err := openStdin(stdin)
+			if errors.Is(err, ErrNoStdin) {
+				return
+			}
+			if err != nil {
+				yield("", err)
+				return
+			}
+			yieldAll(Lines(r, sep), yield)
+			return
+		}
+		for _, g := range glob {
+			if !yieldFileList(stdin, g, sep, yield) {
+				return
+			}
+		}
+	}
+}
+
+// yields the files listed in one filelist, or on stdin for a -. false once the caller stops, or after
+// an error.
+func yieldFileList(stdin io.Reader, g string, sep string, yield func(string, error) bool) bool {
+	if g == STDIN {
+		if stdin == nil {
+			yield("", errExplicitStdin)
+			return false
+		}
+		return yieldAll(Lines(stdin, sep), yield)
+	}
+	f, err := os.Open(g)
+	if err != nil {
+		yield("", fmt.Errorf("error opening file: %w", err))
+		return false
+	}
+	defer f.Close()
+	// NOTE: a filelist file is always one per line:
+	return yieldAll(Lines(f, Newline), yield)
+}
+
+func yieldAll(seq iter.Seq2[string, error], yield func(string, error) bool) bool {
+	for f, err := range seq {
+		if !yield(f, err) || err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// opens the given filename and splits into lines, keeping blank lines and comments, for um sort to
+// preserve in its key:
 func FileListSplit(f string) ([]string, error) {
 	if f == "" {
-		return nil, errors.New("filename is empty")
+		return nil, fmt.Errorf("%w: filename is empty", cmd.ErrUsage)
 	}
 	dat, err := os.ReadFile(f)
 	if err != nil {
 		return nil, fmt.Errorf("error opening file: %w", err)
 	}
-	s := strings.TrimSuffix(string(dat), Newline)
+	// an empty file has no lines, rather than one empty line:
+	if len(dat) == 0 {
+		return nil, nil
+	}
+	s := strings.ReplaceAll(string(dat), "\r\n", Newline)
+	s = strings.TrimSuffix(s, Newline)
 	return strings.Split(s, Newline), nil
 }
+
+// writes atomically by renaming a temp file over the target, so that a failed write never leaves a
+// truncated file behind. an existing file keeps its permissions.
+func WriteFile(file string, content []byte) (err error) {
+	mode := os.FileMode(0664)
+	if stat, err := os.Stat(file); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	// clean up on any failure before the rename:
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
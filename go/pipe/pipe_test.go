@@ -0,0 +1,171 @@
+package pipe
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		sep  string
+		want []string
+	}{
+		{"lines", "01.md\n02.md\n", Newline, []string{"01.md", "02.md"}},
+		{"no final newline", "01.md\n02.md", Newline, []string{"01.md", "02.md"}},
+		{"crlf", "01.md\r\n02.md\r\n", Newline, []string{"01.md", "02.md"}},
+		{"blanks and comments", "\n# part one\n01.md\n\n  \n02.md\n\n", Newline, []string{"01.md", "02.md"}},
+		{"empty", "", Newline, []string{}},
+		{"null", "01 foo.md\x00# 02.md\x00\x00", Null, []string{"01 foo.md", "# 02.md"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Split(tc.s, tc.sep))
+		})
+	}
+}
+
+func TestGetStdin(t *testing.T) {
+	got, err := GetStdin(strings.NewReader("01.md\n02.md\n"), Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md", "02.md"}, got)
+
+	_, err = GetStdin(nil, Newline)
+	assert.ErrorIs(t, err, ErrNoStdin)
+	// as from </dev/null or a closed pipe:
+	_, err = GetStdin(strings.NewReader(""), Newline)
+	assert.ErrorIs(t, err, ErrNoStdin)
+	_, err = GetStdin(strings.NewReader("\n\n"), Newline)
+	assert.ErrorIs(t, err, ErrNoStdin)
+
+	// a slow writer is waited for, however long it takes:
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "01.md\n")
+		w.Close()
+	}()
+	got, err = GetStdin(r, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md"}, got)
+
+	// a redirected file:
+	f := filepath.Join(t.TempDir(), "list")
+	assert.NoError(t, os.WriteFile(f, []byte("02.md\n"), 0664))
+	file, err := os.Open(f)
+	assert.NoError(t, err)
+	defer file.Close()
+	got, err = GetStdin(file, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"02.md"}, got)
+
+	// a terminal, or /dev/null:
+	null, err := os.Open(os.DevNull)
+	assert.NoError(t, err)
+	defer null.Close()
+	_, err = GetStdin(null, Newline)
+	assert.ErrorIs(t, err, ErrNoStdin)
+}
+
+func TestGetStdinTimeout(t *testing.T) {
+	// a pipe left open which never writes is no stdin:
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	_, err = GetStdin(WithTimeout(r, 10*time.Millisecond), Newline)
+	assert.ErrorIs(t, err, ErrNoStdin)
+
+	// but one which writes in time is read until EOF:
+	r, w, err = os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	go func() {
+		io.WriteString(w, "01.md\n")
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "02.md\n")
+		w.Close()
+	}()
+	got, err := GetStdin(WithTimeout(r, time.Second), Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md", "02.md"}, got)
+
+	// and an explicit - waits however long it takes:
+	r, w, err = os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "03.md\n")
+		w.Close()
+	}()
+	got, err = GlobOrStdin(WithTimeout(r, 10*time.Millisecond), []string{STDIN}, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"03.md"}, got)
+}
+
+func TestGetStdinSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "um")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("unix", filepath.Join(dir, "sock"))
+	assert.NoError(t, err)
+	defer l.Close()
+	conn, err := net.Dial("unix", l.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	peer, err := l.Accept()
+	assert.NoError(t, err)
+	defer peer.Close()
+	sock, err := conn.(*net.UnixConn).File()
+	assert.NoError(t, err)
+	defer sock.Close()
+
+	// as an IDE runner leaves it, open and silent, which is no stdin rather than an error:
+	_, err = GetStdin(WithTimeout(sock, 10*time.Millisecond), Newline)
+	assert.ErrorIs(t, err, ErrNoStdin)
+}
+
+func TestGlobOrStdin(t *testing.T) {
+	got, err := GlobOrStdin(strings.NewReader("02.md\n"), []string{"01.md", STDIN, "03.md"}, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md", "02.md", "03.md"}, got)
+
+	// arguments win over a stdin which happens to be open:
+	got, err = GlobOrStdin(strings.NewReader("02.md\n"), []string{"01.md"}, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md"}, got)
+
+	_, err = GlobOrStdin(nil, nil, Newline)
+	assert.ErrorIs(t, err, cmd.ErrUsage)
+	_, err = GlobOrStdin(nil, []string{STDIN}, Newline)
+	assert.ErrorIs(t, err, cmd.ErrUsage)
+}
+
+func TestFileListFromGlobOrStdin(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "list.um")
+	assert.NoError(t, os.WriteFile(f, []byte("# part one\r\n01.md\r\n\r\n02.md\r\n"), 0664))
+
+	got, err := FileListFromGlobOrStdin(nil, []string{f}, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md", "02.md"}, got)
+
+	got, err = FileListFromGlobOrStdin(strings.NewReader("03.md\x0004.md\x00"), []string{f, STDIN}, Null)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.md", "02.md", "03.md", "04.md"}, got)
+
+	got, err = FileListFromGlobOrStdin(strings.NewReader("03.md\n"), nil, Newline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"03.md"}, got)
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/brtholomy/um/go/config"
+)
+
+const (
+	// um foo runs um-foo from PATH, when foo is not a builtin command:
+	PREFIX = "um-"
+	// the collection root handed to a plugin, which is the directory um was run in:
+	ROOT_ENV = "UM_ROOT"
+)
+
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// finds the plugin for an unknown subcommand.
+func Find(name string) (Plugin, bool) {
+	// a name with a path in it would run something other than a plugin:
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return Plugin{}, false
+	}
+	p, err := exec.LookPath(PREFIX + name)
+	if err != nil {
+		return Plugin{}, false
+	}
+	return Plugin{name, p}, true
+}
+
+// every plugin on PATH, sorted by name. the first of the same name on PATH wins, as it would when run.
+func List() []Plugin {
+	seen := map[string]bool{}
+	plugins := []Plugin{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, PREFIX+"*"))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			name := strings.TrimPrefix(filepath.Base(m), PREFIX)
+			if seen[name] || !executable(m) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{name, m})
+		}
+	}
+	slices.SortFunc(plugins, func(a, b Plugin) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return plugins
+}
+
+func executable(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Mode().IsRegular() && stat.Mode().Perm()&0111 != 0
+}
+
+// the environment a plugin runs with: ours, plus where the collection and its config are.
+func environ() []string {
+	env := os.Environ()
+	if root, err := os.Getwd(); err == nil {
+		env = append(env, ROOT_ENV+"="+root)
+	}
+	if p := config.Path(); p != "" {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		env = append(env, config.ENV+"="+p)
+	}
+	return env
+}
+
+// runs the plugin with the remaining args. a plugin which exits non-zero returns an *exec.ExitError,
+// whose exit status um passes on as its own.
+func (p Plugin) Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := exec.CommandContext(ctx, p.Path, args...)
+	c.Env = environ()
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
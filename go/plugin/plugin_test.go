@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brtholomy/um/go/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const SCRIPT = "#!/bin/sh\necho \"$UM_ROOT\" \"$UM_CONFIG\" \"$@\"\n"
+
+func writeScript(t *testing.T, dir, name string, mode os.FileMode) string {
+	p := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(p, []byte(SCRIPT), mode))
+	return p
+}
+
+func TestList(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	foo := writeScript(t, first, "um-foo", 0755)
+	writeScript(t, second, "um-foo", 0755)
+	bar := writeScript(t, second, "um-bar", 0755)
+	writeScript(t, second, "um-notexec", 0644)
+	writeScript(t, second, "other", 0755)
+	t.Setenv("PATH", first+string(filepath.ListSeparator)+second)
+
+	assert.Equal(t, []Plugin{{"bar", bar}, {"foo", foo}}, List())
+
+	p, ok := Find("foo")
+	assert.True(t, ok)
+	assert.Equal(t, Plugin{"foo", foo}, p)
+	for _, name := range []string{"notexec", "other", "", "../um-foo"} {
+		_, ok := Find(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestRun(t *testing.T) {
+	bin := t.TempDir()
+	writeScript(t, bin, "um-foo", 0755)
+	t.Setenv("PATH", bin)
+	root := t.TempDir()
+	t.Chdir(root)
+	assert.NoError(t, os.WriteFile(config.FILE, nil, 0664))
+	t.Setenv(config.ENV, "")
+
+	p, ok := Find("foo")
+	assert.True(t, ok)
+	stdout := bytes.Buffer{}
+	assert.NoError(t, p.Run(context.Background(), []string{"a", "b"}, nil, &stdout, &bytes.Buffer{}))
+	assert.Equal(t, root+" "+filepath.Join(root, config.FILE)+" a b\n", stdout.String())
+}
@@ -0,0 +1,253 @@
+package related
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Related
+	SUMMARY = "list the files most like a given file, by shared tags, links and optionally text"
+)
+
+type options struct {
+	File         flags.Arg
+	Top          flags.Int
+	Text         flags.Bool
+	Scores       flags.Bool
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Arg{"", "um file to find relations for", "zettel"},
+		flags.Int{"--top", "-n", 10, "number of files to list. 0 lists all", "none", &flags.Default{}},
+		flags.Bool{"--text", "-t", false, "also score by textual similarity", &flags.Default{}},
+		flags.Bool{"--scores", "-s", false, "precede each file with a comment giving its score", nil},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+type score struct {
+	file  string
+	tags  float64
+	links float64
+	text  float64
+	// the tags both files have, for --scores:
+	shared []string
+}
+
+func (s score) total() float64 {
+	return s.tags + s.links + s.text
+}
+
+// inverse document frequency: a tag or word in every file says nothing, a rare one says a lot.
+func idf(df map[string]int, n int) func(string) float64 {
+	return func(t string) float64 {
+		return math.Log(1 + float64(n)/float64(max(df[t], 1)))
+	}
+}
+
+// the weighted jaccard index of two tag lists, each tag weighted by its rarity.
+func tagScore(a, b []string, weight func(string) float64) (float64, []string) {
+	union := map[string]bool{}
+	for _, t := range a {
+		union[t] = true
+	}
+	shared := []string{}
+	for _, t := range b {
+		if union[t] && !slices.Contains(shared, t) {
+			shared = append(shared, t)
+		}
+		union[t] = true
+	}
+	slices.Sort(shared)
+	var num, den float64
+	for t := range union {
+		den += weight(t)
+	}
+	for _, t := range shared {
+		num += weight(t)
+	}
+	if den == 0 {
+		return 0, shared
+	}
+	return num / den, shared
+}
+
+// the jaccard index of the files each links to, counting each file as linked to itself. so a direct
+// link either way scores as well as a shared one.
+func linkScore(a, b tag.Entry) float64 {
+	la := append(a.Links(), a.Filename())
+	lb := append(b.Links(), b.Filename())
+	shared := 0
+	for _, l := range la {
+		if slices.Contains(lb, l) {
+			shared++
+		}
+	}
+	union := len(la) + len(lb) - shared
+	return float64(shared) / float64(union)
+}
+
+// lowercased words of the body.
+func terms(e tag.Entry) map[string]int {
+	tf := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(e.Body()), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		tf[w]++
+	}
+	return tf
+}
+
+// cosine similarity of two tf-idf vectors.
+func cosine(a, b map[string]int, weight func(string) float64) float64 {
+	var dot, na, nb float64
+	for t, n := range a {
+		wa := float64(n) * weight(t)
+		na += wa * wa
+		if m, ok := b[t]; ok {
+			dot += wa * float64(m) * weight(t)
+		}
+	}
+	for t, m := range b {
+		wb := float64(m) * weight(t)
+		nb += wb * wb
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// the tags of an entry with their ancestors, once each. so science/physics and science/biology share
+// science.
+func withAncestors(tags []string) []string {
+	all := slices.Clone(tags)
+	for _, t := range tags {
+		all = append(all, tag.Ancestors(t)...)
+	}
+	return slices.Compact(slices.Sorted(slices.Values(all)))
+}
+
+// scores every other entry against target, best first, ties by name. only files with something in
+// common are kept.
+func related(target tag.Entry, entries []tag.Entry, text bool) []score {
+	tagDF := map[string]int{}
+	for _, e := range entries {
+		for _, t := range withAncestors(e.Tags()) {
+			tagDF[t]++
+		}
+	}
+	tagWeight := idf(tagDF, len(entries))
+
+	var tfs map[string]map[string]int
+	var termWeight func(string) float64
+	if text {
+		tfs = map[string]map[string]int{target.Filename(): terms(target)}
+		termDF := map[string]int{}
+		for _, e := range entries {
+			tfs[e.Filename()] = terms(e)
+			for t := range tfs[e.Filename()] {
+				termDF[t]++
+			}
+		}
+		termWeight = idf(termDF, len(entries))
+	}
+
+	targetTags := withAncestors(target.Tags())
+	scores := []score{}
+	for _, e := range entries {
+		if e.Filename() == target.Filename() {
+			continue
+		}
+		s := score{file: e.Filename()}
+		s.tags, s.shared = tagScore(targetTags, withAncestors(e.Tags()), tagWeight)
+		s.links = linkScore(target, e)
+		if text {
+			s.text = cosine(tfs[target.Filename()], tfs[e.Filename()], termWeight)
+		}
+		if s.total() > 0 {
+			scores = append(scores, s)
+		}
+	}
+	slices.SortStableFunc(scores, func(a, b score) int {
+		return cmp.Or(cmp.Compare(b.total(), a.total()), strings.Compare(a.file, b.file))
+	})
+	return scores
+}
+
+// a filename per line, so that the output is itself a .um filelist. --scores adds comments, which a
+// filelist ignores.
+func printScores(w io.Writer, scores []score, withScores bool) {
+	for _, s := range scores {
+		if withScores {
+			fmt.Fprintf(w, "%s%.3f tags=%.3f links=%.3f text=%.3f shared=%s\n", pipe.Comment, s.total(), s.tags, s.links, s.text, strings.Join(s.shared, ","))
+		}
+		fmt.Fprintln(w, s.file)
+	}
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
+	// BORK: by hand for now:
+	if !opts.File.IsSet() {
+		return help.HelpRequired("[file]")
+	}
+
+	entries, err := tag.EntriesGlobOrStdin(stdin, last.GLOB, pipe.Sep(opts.Null.Val))
+	if err != nil {
+		return err
+	}
+	// the file need not be in the collection it's compared against:
+	i := slices.IndexFunc(entries, func(e tag.Entry) bool {
+		return e.Filename() == filepath.Base(opts.File.Val)
+	})
+	var target tag.Entry
+	if i >= 0 {
+		target = entries[i]
+	} else if target, err = tag.ParseFile(opts.File.Val); err != nil {
+		return err
+	}
+
+	scores := related(target, entries, opts.Text.Val)
+	if opts.Top.Val > 0 && opts.Top.Val < len(scores) {
+		scores = scores[:opts.Top.Val]
+	}
+	printScores(stdout, scores, opts.Scores.Val)
+	return nil
+}
@@ -0,0 +1,74 @@
+package related
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/brtholomy/um/go/tag"
+	"github.com/stretchr/testify/assert"
+)
+
+// a small collection in testdata: common is on nearly everything, rare on two files only.
+const TEST_PATTERN string = "./testdata/*.md"
+
+func testEntries(t *testing.T) []tag.Entry {
+	entries, err := tag.EntriesGlobOrStdin(nil, TEST_PATTERN, "\n")
+	assert.NoError(t, err)
+	return entries
+}
+
+func files(scores []score) []string {
+	ff := []string{}
+	for _, s := range scores {
+		ff = append(ff, s.file)
+	}
+	return ff
+}
+
+func TestRelated(t *testing.T) {
+	entries := testEntries(t)
+	scores := related(entries[0], entries, false)
+	// sharing a rare tag counts for more than sharing a common one, and a link counts too:
+	assert.Equal(t, []string{"02.rare.md", "04.link.md", "03.common.md", "06.none.md"}, files(scores))
+	assert.Equal(t, []string{"common", "rare"}, scores[0].shared)
+	assert.InDelta(t, 1.0, scores[0].tags, 0.001)
+	assert.InDelta(t, 0.5, scores[1].links, 0.001)
+
+	// the text of 05 is all but the same:
+	scores = related(entries[0], entries, true)
+	assert.Contains(t, files(scores), "05.text.md")
+	for _, s := range scores {
+		if s.file == "05.text.md" {
+			assert.Greater(t, s.text, 0.5)
+		}
+	}
+}
+
+func TestRelatedAncestors(t *testing.T) {
+	entries := testEntries(t)
+	i := slices.IndexFunc(entries, func(e tag.Entry) bool { return e.Filename() == "07.optics.md" })
+	scores := related(entries[i], entries, false)
+	// a sibling tag shares its parent:
+	assert.Equal(t, []string{"08.biology.md"}, files(scores))
+	assert.Equal(t, []string{"science"}, scores[0].shared)
+}
+
+func TestTagScore(t *testing.T) {
+	one := func(string) float64 { return 1 }
+	s, shared := tagScore([]string{"a", "b"}, []string{"b", "c", "b"}, one)
+	assert.InDelta(t, 1.0/3, s, 0.001)
+	assert.Equal(t, []string{"b"}, shared)
+	s, _ = tagScore(nil, nil, one)
+	assert.Equal(t, 0.0, s)
+}
+
+func TestPrintScores(t *testing.T) {
+	w := bytes.Buffer{}
+	scores := []score{{"02.md", 0.5, 0.25, 0, []string{"x"}}, {"03.md", 0.1, 0, 0, nil}}
+	printScores(&w, scores, false)
+	assert.Equal(t, "02.md\n03.md\n", w.String())
+	w.Reset()
+	printScores(&w, scores, true)
+	assert.Equal(t, "# 0.750 tags=0.500 links=0.250 text=0.000 shared=x\n02.md\n# 0.100 tags=0.100 links=0.000 text=0.000 shared=\n03.md\n", w.String())
+}
@@ -0,0 +1,200 @@
+package serve
+
+import (
+	"html/template"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/brtholomy/um/go/tag"
+	"github.com/brtholomy/um/go/tags"
+)
+
+// the stylesheet of every page. exported for um site.
+const STYLE = `body { max-width: 46em; margin: 2em auto; padding: 0 1em; font: 17px/1.5 Georgia, serif; color: #222; }
+nav { display: flex; gap: 1em; align-items: baseline; border-bottom: 1px solid #ccc; padding-bottom: .5em; }
+nav input[name=q] { flex: 1; font: inherit; }
+a { color: #1a5fb4; text-decoration: none; } a:hover { text-decoration: underline; }
+.meta, .meta a { color: #666; font-size: .9em; }
+.tags a { margin-right: .6em; }
+ul.files, ul.links { list-style: none; padding-left: 0; }
+pre { background: #f4f4f4; padding: .6em; overflow-x: auto; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #555; }
+`
+
+// every page shares the head and the search form, which takes the same query as um tag.
+const LAYOUT = `{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · um</title>
+<style>
+` + STYLE + `</style>
+</head>
+<body>
+<nav>
+<a href="/">um</a>
+<form action="/" method="get" style="display: flex; flex: 1; gap: .5em">
+<input name="q" value="{{.Query}}" placeholder="tag query: foo+bar, sci*, !draft, @saved">
+<label><input type="checkbox" name="invert" value="true"{{if .Invert}} checked{{end}}> invert</label>
+</form>
+</nav>
+<main>
+{{end}}
+
+{{define "foot"}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "files"}}<ul class="files">
+{{range .}}<li><a href="/file/{{.Filename}}">{{.Title}}</a> <span class="meta">{{.Filename}}{{with .Date}} · {{.}}{{end}}</span></li>
+{{else}}<li class="meta">no files</li>
+{{end}}</ul>
+{{end}}
+
+{{define "index"}}{{template "head" .}}
+{{if .Query}}<h1>{{.Query}}</h1>
+<p class="meta">{{len .Files}} files · <a href="/cat?q={{.Query}}{{if .Invert}}&amp;invert=true{{end}}">read them together</a></p>
+{{template "files" .Files}}
+{{else}}{{with .Lists}}<h2>filelists</h2>
+<ul class="files">
+{{range .}}<li><a href="/cat?list={{.}}">{{.}}</a></li>
+{{end}}</ul>
+{{end}}<h2>tags</h2>
+<p class="tags">{{range .Tags}}<a href="/?q={{.Name}}">{{.Name}}</a><span class="meta">{{.Files}}</span> {{end}}</p>
+<h2>files</h2>
+{{template "files" .Files}}
+{{end}}{{template "foot"}}{{end}}
+
+{{define "file"}}{{template "head" .}}
+<p class="meta">{{.Entry.Filename}}{{with .Entry.Date}} · {{.}}{{end}}</p>
+<p class="tags">{{range .Entry.Tags}}<a href="/?q={{.}}">+ {{.}}</a>{{end}}</p>
+<h1>{{.Entry.Title}}</h1>
+{{.Body}}
+{{with .Entry.Backlinks}}<h2>linked from</h2>
+<ul class="links">
+{{range .}}<li><a href="/file/{{.}}">{{.}}</a></li>
+{{end}}</ul>
+{{end}}{{template "foot"}}{{end}}
+
+{{define "cat"}}{{template "head" .}}
+<p class="meta">{{len .Files}} files{{with .List}} · {{.}}{{end}}</p>
+{{.Body}}
+{{template "foot"}}{{end}}
+`
+
+var pages = template.Must(template.New("").Parse(LAYOUT))
+
+// what every page has, and what only some do.
+type page struct {
+	Title  string
+	Query  string
+	Invert bool
+	Files  []Entry
+	Tags   []tags.Tag
+	Lists  []string
+	Entry  Entry
+	List   string
+	Body   template.HTML
+}
+
+func newPage(r *http.Request, title string) page {
+	return page{Title: title, Query: r.FormValue("q"), Invert: r.FormValue("invert") == "true"}
+}
+
+func writePage(w http.ResponseWriter, name string, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages.ExecuteTemplate(w, name, p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writePageError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), status(err))
+}
+
+// the tags with the files having each, parents counting their descendants', by name. exported for
+// um site.
+func TagCounts(entries []tag.Entry) []tags.Tag {
+	rolled := tag.Rollup(tag.MakeTagmap(entries))
+	tt := make([]tags.Tag, 0, len(rolled))
+	for _, name := range slices.Sorted(maps.Keys(rolled)) {
+		tt = append(tt, tags.Tag{Name: name, Files: len(rolled[name])})
+	}
+	return tt
+}
+
+// the tags, the filelists and every file, or the files matching a query.
+func pageIndex(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	p := newPage(r, "um")
+	if p.Query == "" {
+		p.Tags = TagCounts(entries)
+		p.Lists = filelists()
+	} else {
+		p.Title = p.Query
+	}
+	matched, err := match(r, entries)
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	for _, e := range matched {
+		p.Files = append(p.Files, newEntry(e))
+	}
+	writePage(w, "index", p)
+}
+
+func pageFile(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	e, ok := find(entries, r.PathValue("file"))
+	if !ok {
+		writePageError(w, notFound(r.PathValue("file")))
+		return
+	}
+	p := newPage(r, e.Title())
+	p.Entry = newEntry(e)
+	p.Entry.Backlinks = backlinks(entries, e.Filename())
+	p.Body = Render(e.Body(), fileHref)
+	writePage(w, "file", p)
+}
+
+// a filelist or query composed by um cat, as one page.
+func pageCat(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	files, err := compose(r, entries)
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	content, err := catFiles(r.Context(), r, files)
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	p := newPage(r, r.FormValue("list"))
+	p.List = r.FormValue("list")
+	if p.List == "" {
+		p.Title = p.Query
+	}
+	for _, f := range files {
+		e, _ := find(entries, f)
+		p.Files = append(p.Files, newEntry(e))
+	}
+	p.Body = Render(content, fileHref)
+	writePage(w, "cat", p)
+}
@@ -0,0 +1,150 @@
+package serve
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/brtholomy/um/go/tag"
+)
+
+// a filename alone on its line, which is how um files link to each other:
+var fileLinkRegexp = regexp.MustCompile(`^` + strings.TrimPrefix(tag.LINK_REGEXP, `(?m)^`))
+
+var (
+	headingRegexp = regexp.MustCompile(`^(#{1,6}) +(.*)$`)
+	hrRegexp      = regexp.MustCompile(`^(---+|\*\*\*+|___+)$`)
+	ulRegexp      = regexp.MustCompile(`^[-*+] +(.*)$`)
+	olRegexp      = regexp.MustCompile(`^[0-9]+\. +(.*)$`)
+	linkRegexp    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRegexp  = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	emRegexp      = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+const FENCE = "```"
+
+// the href of a file's page.
+func fileHref(f string) string {
+	return FILE_PATH + f
+}
+
+// where a markdown link may point: a um file is linked to its page by href, and anything with a scheme
+// other than the web's or mail is dropped, since it could be javascript.
+func safeHref(u string, href func(string) string) (string, bool) {
+	if fileLinkRegexp.MatchString(u) {
+		return href(u), true
+	}
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		return u, true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return u, true
+	}
+	return "", false
+}
+
+// the inline markup of a line: code spans, links, strong and emphasis. everything else is escaped.
+func inline(s string, href func(string) string) string {
+	sb := strings.Builder{}
+	// odd segments are between backticks, and kept literally:
+	for i, seg := range strings.Split(s, "`") {
+		if i%2 == 1 {
+			sb.WriteString("<code>" + html.EscapeString(seg) + "</code>")
+			continue
+		}
+		seg = html.EscapeString(seg)
+		seg = linkRegexp.ReplaceAllStringFunc(seg, func(m string) string {
+			sub := linkRegexp.FindStringSubmatch(m)
+			u, ok := safeHref(html.UnescapeString(sub[2]), href)
+			if !ok {
+				return sub[1]
+			}
+			return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(u), sub[1])
+		})
+		seg = strongRegexp.ReplaceAllString(seg, "<strong>$1</strong>")
+		seg = emRegexp.ReplaceAllString(seg, "<em>$1</em>")
+		sb.WriteString(seg)
+	}
+	return sb.String()
+}
+
+// renders the little markdown um files are written in: headings, paragraphs, lists, quotes, rules
+// and fenced code, with inline code, links, strong and emphasis. a filename alone on its line links
+// to that file's page, at href. anything else is escaped, so a file never becomes markup it didn't
+// ask for. exported for um site.
+func Render(md string, href func(string) string) template.HTML {
+	sb := strings.Builder{}
+	// the open block, closed by a blank line or a block of another kind:
+	open := ""
+	closeBlock := func() {
+		switch open {
+		case "p":
+			sb.WriteString("</p>\n")
+		case "ul", "links":
+			sb.WriteString("</ul>\n")
+		case "ol":
+			sb.WriteString("</ol>\n")
+		}
+		open = ""
+	}
+	openBlock := func(block, tag string) {
+		if open != block {
+			closeBlock()
+			sb.WriteString(tag)
+			open = block
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == "":
+			closeBlock()
+		case strings.HasPrefix(trimmed, FENCE):
+			closeBlock()
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), FENCE); i++ {
+				code = append(code, lines[i])
+			}
+			sb.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case headingRegexp.MatchString(trimmed):
+			closeBlock()
+			m := headingRegexp.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&sb, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2], href), len(m[1]))
+		case hrRegexp.MatchString(trimmed):
+			closeBlock()
+			sb.WriteString("<hr>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			closeBlock()
+			quoted := []string{}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			i--
+			sb.WriteString("<blockquote>\n" + string(Render(strings.Join(quoted, "\n"), href)) + "</blockquote>\n")
+		case fileLinkRegexp.MatchString(trimmed):
+			openBlock("links", `<ul class="links">`+"\n")
+			fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href(trimmed)), html.EscapeString(trimmed))
+		case ulRegexp.MatchString(trimmed):
+			openBlock("ul", "<ul>\n")
+			sb.WriteString("<li>" + inline(ulRegexp.FindStringSubmatch(trimmed)[1], href) + "</li>\n")
+		case olRegexp.MatchString(trimmed):
+			openBlock("ol", "<ol>\n")
+			sb.WriteString("<li>" + inline(olRegexp.FindStringSubmatch(trimmed)[1], href) + "</li>\n")
+		case open == "p":
+			sb.WriteString("\n" + inline(trimmed, href))
+		default:
+			openBlock("p", "<p>")
+			sb.WriteString(inline(trimmed, href))
+		}
+	}
+	closeBlock()
+	return template.HTML(sb.String())
+}
@@ -0,0 +1,374 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/brtholomy/um/go/cat"
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
+	"github.com/brtholomy/um/go/tags"
+)
+
+const (
+	CMD     = cmd.Serve
+	SUMMARY = "serve the collection over http, as a JSON API and a browsable UI"
+)
+
+// the pages of the UI, beside the API under API_PATH:
+const (
+	API_PATH  = "/api/"
+	FILE_PATH = "/file/"
+	CAT_PATH  = "/cat"
+)
+
+type options struct {
+	Addr flags.String
+	Help flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.String{"--addr", "-a", "localhost:8080", "address to listen on. only localhost by default, since there is no auth", "none", &flags.Default{}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// an entry as the API gives it. the body and backlinks are only given for a single entry.
+type Entry struct {
+	Filename  string   `json:"filename"`
+	Title     string   `json:"title"`
+	Date      string   `json:"date,omitempty"`
+	Tags      []string `json:"tags"`
+	Links     []string `json:"links"`
+	Words     int      `json:"words"`
+	Body      string   `json:"body,omitempty"`
+	Backlinks []string `json:"backlinks,omitempty"`
+}
+
+func newEntry(e tag.Entry) Entry {
+	entry := Entry{
+		Filename: e.Filename(),
+		Title:    e.Title(),
+		Tags:     e.Tags(),
+		Links:    e.Links(),
+		Words:    e.Words(),
+	}
+	if entry.Tags == nil {
+		entry.Tags = []string{}
+	}
+	if !e.Date().IsZero() {
+		entry.Date = e.Date().Format(tag.DATE_FORMAT)
+	}
+	return entry
+}
+
+// a composition as the API gives it: the files of a filelist or query, and what um cat makes of them.
+type Composition struct {
+	Files   []string `json:"files"`
+	Content string   `json:"content"`
+}
+
+// the collection is read afresh for every request, so that what's served is never stale. reading
+// a few thousand headers is quick enough for one person browsing.
+func load() ([]tag.Entry, error) {
+	return tag.EntriesGlobOrStdin(nil, last.GLOB, pipe.Newline)
+}
+
+func find(entries []tag.Entry, filename string) (tag.Entry, bool) {
+	i := slices.IndexFunc(entries, func(e tag.Entry) bool { return e.Filename() == filename })
+	if i < 0 {
+		return tag.Entry{}, false
+	}
+	return entries[i], true
+}
+
+// the files linking to filename, in order.
+func backlinks(entries []tag.Entry, filename string) []string {
+	linking := []string{}
+	for _, e := range entries {
+		if slices.Contains(e.Links(), filename) {
+			linking = append(linking, e.Filename())
+		}
+	}
+	return linking
+}
+
+// the entries matching the query in q, with date and invert as um tag takes them, in the order of a
+// saved query's key. all of them without a query.
+func match(r *http.Request, entries []tag.Entry) ([]tag.Entry, error) {
+	q := r.FormValue("q")
+	if q == "" {
+		return entries, nil
+	}
+	invert, _ := strconv.ParseBool(r.FormValue("invert"))
+	saved, err := tag.Resolve(q, r.FormValue("date"), invert)
+	if err != nil {
+		return nil, err
+	}
+	files, err := saved.Filelist(entries)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]tag.Entry, 0, len(files))
+	for _, f := range files {
+		if e, ok := find(entries, f); ok {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// the files of a composition: those of the filelist in list, or else those matching the query. only
+// files of the collection are composed, so that a filelist can't serve anything else.
+func compose(r *http.Request, entries []tag.Entry) ([]string, error) {
+	list := r.FormValue("list")
+	if list == "" {
+		matched, err := match(r, entries)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]string, len(matched))
+		for i, e := range matched {
+			files[i] = e.Filename()
+		}
+		return files, nil
+	}
+	if !filepath.IsLocal(list) || filepath.Ext(list) != tag.LIST_EXT {
+		return nil, fmt.Errorf("%w: not a filelist: %s", cmd.ErrUsage, list)
+	}
+	dat, err := os.ReadFile(list)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, l := range pipe.Split(string(dat), pipe.Newline) {
+		if _, ok := find(entries, l); ok {
+			files = append(files, l)
+		}
+	}
+	return files, nil
+}
+
+// runs um cat on the files, as it would run on a filelist, so that its defaults apply here too. the
+// titles are kept by default, since a page without them is hard to follow.
+func catFiles(ctx context.Context, r *http.Request, files []string) (string, error) {
+	if len(files) == 0 {
+		return "", nil
+	}
+	args := []string{pipe.STDIN}
+	for _, f := range []string{"keep-title", "strip-file-links"} {
+		v := r.FormValue(f)
+		if f == "keep-title" && v == "" {
+			v = "true"
+		}
+		if ok, _ := strconv.ParseBool(v); ok {
+			args = append(args, "--"+f)
+		}
+	}
+	out := bytes.Buffer{}
+	err := cat.Run(ctx, args, strings.NewReader(strings.Join(files, pipe.Newline)), &out, io.Discard)
+	return out.String(), err
+}
+
+// the .um filelists in the current directory, which the UI offers as compositions.
+func filelists() []string {
+	lists, _ := filepath.Glob("*" + tag.LIST_EXT)
+	return lists
+}
+
+// the status for an error, by its kind as for the exit status.
+func status(err error) int {
+	switch {
+	case errors.Is(err, cmd.ErrUsage):
+		return http.StatusBadRequest
+	case errors.Is(err, cmd.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// errors are JSON too, as {"error": "..."}.
+func writeJSONError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status(err))
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func notFound(filename string) error {
+	return fmt.Errorf("%w: %s", cmd.ErrNotFound, filename)
+}
+
+func apiEntries(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err == nil {
+		entries, err = match(r, entries)
+	}
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	out := make([]Entry, len(entries))
+	for i, e := range entries {
+		out[i] = newEntry(e)
+	}
+	writeJSON(w, out)
+}
+
+func apiEntry(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	e, ok := find(entries, r.PathValue("file"))
+	if !ok {
+		writeJSONError(w, notFound(r.PathValue("file")))
+		return
+	}
+	entry := newEntry(e)
+	entry.Body = e.Body()
+	entry.Backlinks = backlinks(entries, e.Filename())
+	writeJSON(w, entry)
+}
+
+func apiBacklinks(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if _, ok := find(entries, r.PathValue("file")); !ok {
+		writeJSONError(w, notFound(r.PathValue("file")))
+		return
+	}
+	writeJSON(w, backlinks(entries, r.PathValue("file")))
+}
+
+// um tags --format json, with its pattern, sort, date and tree.
+func apiTags(w http.ResponseWriter, r *http.Request) {
+	args := []string{"--format", string(tags.JSON)}
+	if p := r.FormValue("pattern"); p != "" {
+		args = append(args, p)
+	}
+	for _, f := range []string{"sort", "date"} {
+		if v := r.FormValue(f); v != "" {
+			args = append(args, "--"+f, v)
+		}
+	}
+	if tree, _ := strconv.ParseBool(r.FormValue("tree")); tree {
+		args = append(args, "--tree")
+	}
+	out := bytes.Buffer{}
+	if err := tags.Run(r.Context(), args, nil, &out, io.Discard); err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out.Bytes())
+}
+
+// the saved queries by name.
+func apiQueries(w http.ResponseWriter, r *http.Request) {
+	names, err := tag.SavedNames()
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	queries := map[string]tag.Saved{}
+	for _, n := range names {
+		if queries[n], err = tag.LoadSaved(n); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+	}
+	writeJSON(w, queries)
+}
+
+func apiCat(w http.ResponseWriter, r *http.Request) {
+	entries, err := load()
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	files, err := compose(r, entries)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	content, err := catFiles(r.Context(), r, files)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	writeJSON(w, Composition{files, content})
+}
+
+// the API and the UI. exported for embedding um in another server.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+API_PATH+"entries", apiEntries)
+	mux.HandleFunc("GET "+API_PATH+"entries/{file}", apiEntry)
+	mux.HandleFunc("GET "+API_PATH+"backlinks/{file}", apiBacklinks)
+	mux.HandleFunc("GET "+API_PATH+"tags", apiTags)
+	mux.HandleFunc("GET "+API_PATH+"queries", apiQueries)
+	mux.HandleFunc("GET "+API_PATH+"cat", apiCat)
+	mux.HandleFunc("GET /{$}", pageIndex)
+	mux.HandleFunc("GET "+FILE_PATH+"{file}", pageFile)
+	mux.HandleFunc("GET "+CAT_PATH, pageCat)
+	return mux
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", opts.Addr.Val)
+	if err != nil {
+		return fmt.Errorf("%w: %w", cmd.ErrIO, err)
+	}
+	srv := &http.Server{Handler: Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(stderr, "um %s: listening on http://%s\n", CMD, ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%w: %w", cmd.ErrIO, err)
+	}
+	return nil
+}
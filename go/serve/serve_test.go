@@ -0,0 +1,216 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+	"github.com/brtholomy/um/go/tag"
+)
+
+// the collection shared by the tests of serve, site and watch.
+const (
+	COLLECTION = "../testdata/collection"
+	CONFIG     = "config.toml"
+)
+
+// the shared collection, copied into a temporary directory with its config in effect, and a server on
+// it.
+func collection(t *testing.T) *httptest.Server {
+	src, err := filepath.Abs(COLLECTION)
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	assert.NoError(t, os.CopyFS(dir, os.DirFS(src)))
+	t.Chdir(dir)
+	t.Setenv(config.ENV, filepath.Join(dir, CONFIG))
+	srv := httptest.NewServer(Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := srv.Client().Get(srv.URL + path)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func getJSON(t *testing.T, srv *httptest.Server, path string, v any) int {
+	t.Helper()
+	code, body := get(t, srv, path)
+	assert.NoError(t, json.Unmarshal([]byte(body), v), body)
+	return code
+}
+
+func filenames(entries []Entry) []string {
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Filename)
+	}
+	return names
+}
+
+func TestAPIEntries(t *testing.T) {
+	srv := collection(t)
+
+	entries := []Entry{}
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/entries", &entries))
+	assert.Equal(t, []string{"01.foo.md", "02.bar.md", "03.baz.md", "04.md"}, filenames(entries))
+	assert.Equal(t, Entry{Filename: "01.foo.md", Title: "One", Date: "2024.09.25", Tags: []string{"foo", "science/physics"}, Links: []string{}, Words: 3}, entries[0])
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"?q=foo", []string{"01.foo.md", "02.bar.md"}},
+		// a + must be escaped, as ever in a query string:
+		{"?q=foo%2Bbar", []string{"02.bar.md"}},
+		{"?q=science", []string{"01.foo.md", "03.baz.md", "04.md"}},
+		{"?q=foo&invert=true", []string{"03.baz.md", "04.md"}},
+		{"?q=science&date=2025.01.01-2025.12.31", []string{"03.baz.md"}},
+		{"?q=@foos", []string{"01.foo.md", "02.bar.md"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			entries := []Entry{}
+			assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/entries"+tc.query, &entries))
+			assert.Equal(t, tc.want, filenames(entries))
+		})
+	}
+
+	e := map[string]string{}
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, srv, "/api/entries?q=/(/", &e))
+	assert.Contains(t, e["error"], "invalid regex")
+	// a filelist without a header isn't a saved query:
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv, "/api/entries?q=@reading", &e))
+	assert.Equal(t, "not found: no saved query: @reading", e["error"])
+}
+
+func TestAPIEntry(t *testing.T) {
+	srv := collection(t)
+
+	entry := Entry{}
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/entries/01.foo.md", &entry))
+	assert.Equal(t, "The *first* one.\n", entry.Body)
+	assert.Equal(t, []string{"02.bar.md"}, entry.Backlinks)
+
+	backlinks := []string{}
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/backlinks/01.foo.md", &backlinks))
+	assert.Equal(t, []string{"02.bar.md"}, backlinks)
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/backlinks/03.baz.md", &backlinks))
+	assert.Equal(t, []string{}, backlinks)
+
+	e := map[string]string{}
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv, "/api/entries/05.md", &e))
+	assert.Equal(t, "not found: 05.md", e["error"])
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv, "/api/backlinks/config.toml", &e))
+}
+
+func TestAPITagsAndQueries(t *testing.T) {
+	srv := collection(t)
+
+	tt := []map[string]any{}
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/tags?pattern=sci&sort=count&tree=true", &tt))
+	assert.Equal(t, "science", tt[0]["name"])
+	assert.Equal(t, 3.0, tt[0]["files"])
+
+	queries := map[string]tag.Saved{}
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/queries", &queries))
+	assert.Equal(t, map[string]tag.Saved{"foos": {Name: "foos", Query: "foo"}}, queries)
+}
+
+func TestAPICat(t *testing.T) {
+	srv := collection(t)
+
+	// only the files of the collection are composed, in the order of the filelist:
+	c := Composition{}
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/cat?list=reading.um", &c))
+	assert.Equal(t, []string{"03.baz.md", "01.foo.md"}, c.Files)
+	assert.Equal(t, "\n---\n\n# Three\n\nLast, after [one](01.foo.md).\n\n---\n\n# One\n\nThe *first* one.\n", c.Content)
+
+	assert.Equal(t, http.StatusOK, getJSON(t, srv, "/api/cat?q=bar&keep-title=false", &c))
+	assert.Equal(t, []string{"02.bar.md"}, c.Files)
+	assert.NotContains(t, c.Content, "# Two")
+
+	e := map[string]string{}
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, srv, "/api/cat?list=../config.toml", &e))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, srv, "/api/cat?list=config.toml", &e))
+	assert.Equal(t, http.StatusNotFound, getJSON(t, srv, "/api/cat?list=missing.um", &e))
+}
+
+func TestPages(t *testing.T) {
+	srv := collection(t)
+
+	code, body := get(t, srv, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `<a href="/?q=science%2fphysics">science/physics</a>`)
+	assert.Contains(t, body, `<a href="/cat?list=reading.um">reading.um</a>`)
+	assert.Contains(t, body, `<a href="/file/02.bar.md">Two &lt;b&gt;</a>`)
+
+	code, body = get(t, srv, "/?q=foo%2Bbar")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `<a href="/file/02.bar.md">`)
+	assert.NotContains(t, body, `<a href="/file/01.foo.md">`)
+	assert.Contains(t, body, `<a href="/cat?q=foo%2bbar">`)
+
+	// links become hyperlinks, and the file's own markup is escaped:
+	code, body = get(t, srv, "/file/02.bar.md")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `<li><a href="/file/01.foo.md">01.foo.md</a></li>`)
+	assert.Contains(t, body, `&lt;script&gt;alert(1)&lt;/script&gt;`)
+	assert.NotContains(t, body, `<script>`)
+	_, body = get(t, srv, "/file/01.foo.md")
+	assert.Contains(t, body, `<a href="/file/02.bar.md">02.bar.md</a>`)
+	assert.Contains(t, body, `<em>first</em>`)
+
+	code, body = get(t, srv, "/cat?list=reading.um")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<h1>Three</h1>\n<p>Last, after <a href=\"/file/01.foo.md\">one</a>.</p>\n<hr>\n<h1>One</h1>")
+
+	code, _ = get(t, srv, "/file/05.md")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get(t, srv, "/file/..%2fconfig.toml")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		md   string
+		want string
+	}{
+		{"a\nb\n\nc", "<p>a\nb</p>\n<p>c</p>\n"},
+		{"## Two *em* **strong**", "<h2>Two <em>em</em> <strong>strong</strong></h2>\n"},
+		{"- a\n- `<b>`\n\n1. one", "<ul>\n<li>a</li>\n<li><code>&lt;b&gt;</code></li>\n</ul>\n<ol>\n<li>one</li>\n</ol>\n"},
+		{"```\n<x> *y*\n```", "<pre><code>&lt;x&gt; *y*</code></pre>\n"},
+		{"> quoted\n> more", "<blockquote>\n<p>quoted\nmore</p>\n</blockquote>\n"},
+		{"---", "<hr>\n"},
+		{"01.foo.md\n02.bar.md", "<ul class=\"links\">\n<li><a href=\"/file/01.foo.md\">01.foo.md</a></li>\n<li><a href=\"/file/02.bar.md\">02.bar.md</a></li>\n</ul>\n"},
+		{"[one](01.foo.md) [web](https://x.org/?a=1&b=2)", "<p><a href=\"/file/01.foo.md\">one</a> <a href=\"https://x.org/?a=1&amp;b=2\">web</a></p>\n"},
+		// a link that could run script keeps only its text:
+		{"[x](javascript:alert(1))", "<p>x)</p>\n"},
+		{"[x](JavaScript:alert)", "<p>x</p>\n"},
+		{"<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.md, func(t *testing.T) {
+			assert.Equal(t, tc.want, string(Render(tc.md, fileHref)))
+		})
+	}
+}
+
+func TestRunAddr(t *testing.T) {
+	err := Run(context.Background(), []string{"--addr", "localhost:-1"}, nil, io.Discard, io.Discard)
+	assert.ErrorIs(t, err, cmd.ErrIO)
+}
@@ -0,0 +1,64 @@
+package site
+
+import (
+	"html/template"
+)
+
+// every page is the same: a header, an optional body, and sections of links. Root is the way back to
+// the root of the site, so that every href is relative.
+const LAYOUT = `{{define "page"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · {{.Site}}</title>
+<link rel="stylesheet" href="{{.Root}}` + STYLE + `">
+</head>
+<body>
+<nav>
+<a href="{{.Root}}` + INDEX + `">{{.Site}}</a>
+<a href="{{.Root}}` + TAGS + `">tags</a>
+<a href="{{.Root}}` + DATES + `">dates</a>
+</nav>
+<main>
+{{with .Meta}}<p class="meta">{{range $i, $m := .}}{{if $i}} · {{end}}{{template "link" $m}}{{end}}</p>
+{{end}}{{with .Tags}}<p class="tags">{{range .}}{{template "link" .}}{{end}}</p>
+{{end}}<h1>{{.Title}}</h1>
+{{.Body}}
+{{range .Sections}}<h2>{{.Title}}</h2>
+<ul class="files">
+{{range .Links}}<li>{{template "link" .}}{{with .Meta}} <span class="meta">{{.}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "link"}}{{if .Href}}<a href="{{.Href}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}{{end}}
+`
+
+var pages = template.Must(template.New("").Parse(LAYOUT))
+
+// a link from the root of the site, until the page it's on is added. without an href, only its text
+// is shown.
+type link struct {
+	Href string
+	Text string
+	Meta string
+}
+
+// a titled list of links, as the files of a tag or the files linking to a file.
+type section struct {
+	Title string
+	Links []link
+}
+
+type page struct {
+	Site     string
+	Title    string
+	Root     string
+	Meta     []link
+	Tags     []link
+	Body     template.HTML
+	Sections []section
+}
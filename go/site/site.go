@@ -0,0 +1,445 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/brtholomy/um/go/cat"
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/serve"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Site
+	SUMMARY = "export the collection as a static site of linked html pages"
+)
+
+// the pages of the site, relative to its root:
+const (
+	INDEX    = "index.html"
+	TAGS     = "tags.html"
+	DATES    = "dates.html"
+	STYLE    = "style.css"
+	TAGS_DIR = "tags"
+	DATE_DIR = "dates"
+	LIST_DIR = "lists"
+	EXT      = ".html"
+	// the pages the last build wrote, so that the next prunes only its own:
+	MANIFEST = ".um-site"
+)
+
+// how the archive groups files: by year for its pages, by month within them.
+const (
+	YEAR_FORMAT  = "2006"
+	MONTH_FORMAT = "2006.01"
+)
+
+type options struct {
+	Out   flags.Arg
+	Title flags.String
+	Help  flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Arg{"", "directory to write the site to, created if missing", "dirs"},
+		flags.String{"--title", "-t", "um", "title of the site", "none", &flags.Default{}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// the page of a um file, beside the index: 01.foo.md -> 01.foo.html
+func filePage(f string) string {
+	return strings.TrimSuffix(f, filepath.Ext(f)) + EXT
+}
+
+// the page of a tag, nested as the tag is: science/physics -> tags/science/physics.html. false for a
+// tag which can't be a path inside the site.
+func tagPage(t string) (string, bool) {
+	return TAGS_DIR + "/" + t + EXT, filepath.IsLocal(t) && path.Clean(t) == t
+}
+
+func yearPage(year string) string {
+	return DATE_DIR + "/" + year + EXT
+}
+
+// the page of a .um filelist: reading.um -> lists/reading.html
+func listPage(l string) string {
+	return LIST_DIR + "/" + strings.TrimSuffix(l, tag.LIST_EXT) + EXT
+}
+
+// the way back to the root from a page, so that every link is relative and the site can be served
+// from anywhere, or read from disk.
+func root(page string) string {
+	return strings.Repeat("../", strings.Count(page, "/"))
+}
+
+// the pages of the site by path, built entirely from the entries and filelists given, so that the
+// same collection always makes the same site.
+type site struct {
+	title   string
+	entries []tag.Entry
+	lists   map[string][]string
+	pages   map[string][]byte
+	// the entries by filename, and the entries linking to each, in order:
+	byName  map[string]tag.Entry
+	linking map[string][]tag.Entry
+}
+
+func newSite(title string, entries []tag.Entry, lists map[string][]string) *site {
+	s := &site{
+		title:   title,
+		entries: entries,
+		lists:   lists,
+		pages:   map[string][]byte{STYLE: []byte(serve.STYLE)},
+		byName:  make(map[string]tag.Entry, len(entries)),
+		linking: map[string][]tag.Entry{},
+	}
+	for _, e := range entries {
+		s.byName[e.Filename()] = e
+	}
+	for _, e := range entries {
+		for _, l := range slices.Compact(slices.Sorted(slices.Values(e.Links()))) {
+			s.linking[l] = append(s.linking[l], e)
+		}
+	}
+	return s
+}
+
+// renders a page at path, with its links made relative to it.
+func (s *site) add(path string, p page) error {
+	p.Site = s.title
+	p.Root = root(path)
+	relative := func(links []link) []link {
+		rel := slices.Clone(links)
+		for i := range rel {
+			if rel[i].Href != "" {
+				rel[i].Href = p.Root + rel[i].Href
+			}
+		}
+		return rel
+	}
+	p.Meta, p.Tags = relative(p.Meta), relative(p.Tags)
+	sections := slices.Clone(p.Sections)
+	for i := range sections {
+		sections[i].Links = relative(sections[i].Links)
+	}
+	p.Sections = sections
+	b := bytes.Buffer{}
+	if err := pages.ExecuteTemplate(&b, "page", p); err != nil {
+		return err
+	}
+	s.pages[path] = b.Bytes()
+	return nil
+}
+
+// a link to each entry's page, with its filename and date.
+func fileLinks(entries []tag.Entry) []link {
+	links := make([]link, len(entries))
+	for i, e := range entries {
+		meta := e.Filename()
+		if !e.Date().IsZero() {
+			meta += " · " + e.Date().Format(tag.DATE_FORMAT)
+		}
+		links[i] = link{filePage(e.Filename()), e.Title(), meta}
+	}
+	return links
+}
+
+func (s *site) find(filenames []string) []tag.Entry {
+	found := make([]tag.Entry, 0, len(filenames))
+	for _, f := range filenames {
+		if e, ok := s.byName[f]; ok {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func (s *site) index() error {
+	p := page{Title: s.title}
+	if len(s.lists) > 0 {
+		sec := section{Title: "filelists"}
+		for _, l := range slices.Sorted(maps.Keys(s.lists)) {
+			sec.Links = append(sec.Links, link{listPage(l), l, fmt.Sprintf("%d files", len(s.lists[l]))})
+		}
+		p.Sections = append(p.Sections, sec)
+	}
+	p.Sections = append(p.Sections, section{"files", fileLinks(s.entries)})
+	return s.add(INDEX, p)
+}
+
+// each entry's page: its header, its body with file links to their pages, and the files linking to
+// it.
+func (s *site) files() error {
+	for _, e := range s.entries {
+		path := filePage(e.Filename())
+		p := page{Title: e.Title(), Meta: []link{{Text: e.Filename()}}}
+		if !e.Date().IsZero() {
+			p.Meta = append(p.Meta, link{yearPage(e.Date().Format(YEAR_FORMAT)), e.Date().Format(tag.DATE_FORMAT), ""})
+		}
+		for _, t := range e.Tags() {
+			if href, ok := tagPage(t); ok {
+				p.Tags = append(p.Tags, link{href, "+ " + t, ""})
+			}
+		}
+		r := root(path)
+		p.Body = serve.Render(e.Body(), func(f string) string { return r + filePage(f) })
+		if linking := s.linking[e.Filename()]; len(linking) > 0 {
+			p.Sections = append(p.Sections, section{"linked from", fileLinks(linking)})
+		}
+		if err := s.add(path, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// a page listing every tag, and a page for each with its files and the tags under it. a parent's
+// page has the files of its descendants too, as um tag finds them.
+func (s *site) tags() error {
+	rolled := tag.Rollup(tag.MakeTagmap(s.entries))
+	counts := serve.TagCounts(s.entries)
+	all := section{Title: "tags"}
+	for _, t := range counts {
+		path, ok := tagPage(t.Name)
+		if !ok {
+			continue
+		}
+		all.Links = append(all.Links, link{path, t.Name, fmt.Sprintf("%d files", t.Files)})
+
+		p := page{Title: "+ " + t.Name}
+		for _, a := range tag.Ancestors(t.Name) {
+			if href, ok := tagPage(a); ok {
+				p.Meta = append([]link{{href, a, ""}}, p.Meta...)
+			}
+		}
+		children := section{Title: "tags"}
+		for _, c := range counts {
+			rest, ok := strings.CutPrefix(c.Name, t.Name+tag.SEP)
+			if href, local := tagPage(c.Name); ok && local && !strings.Contains(rest, tag.SEP) {
+				children.Links = append(children.Links, link{href, c.Name, fmt.Sprintf("%d files", c.Files)})
+			}
+		}
+		if len(children.Links) > 0 {
+			p.Sections = append(p.Sections, children)
+		}
+		p.Sections = append(p.Sections, section{"files", fileLinks(s.find(slices.Sorted(maps.Keys(rolled[t.Name]))))})
+		if err := s.add(path, p); err != nil {
+			return err
+		}
+	}
+	return s.add(TAGS, page{Title: "tags", Sections: []section{all}})
+}
+
+// a page listing the years, and a page for each with its files by month. files without a date are
+// in no archive.
+func (s *site) dates() error {
+	years := map[string][]tag.Entry{}
+	for _, e := range s.entries {
+		if !e.Date().IsZero() {
+			y := e.Date().Format(YEAR_FORMAT)
+			years[y] = append(years[y], e)
+		}
+	}
+	archive := section{Title: "years"}
+	for _, y := range slices.Sorted(maps.Keys(years)) {
+		entries := years[y]
+		slices.SortStableFunc(entries, func(a, b tag.Entry) int { return a.Date().Compare(b.Date()) })
+		archive.Links = append(archive.Links, link{yearPage(y), y, fmt.Sprintf("%d files", len(entries))})
+
+		p := page{Title: y}
+		for i := 0; i < len(entries); {
+			month := entries[i].Date().Format(MONTH_FORMAT)
+			j := i
+			for j < len(entries) && entries[j].Date().Format(MONTH_FORMAT) == month {
+				j++
+			}
+			p.Sections = append(p.Sections, section{month, fileLinks(entries[i:j])})
+			i = j
+		}
+		if err := s.add(yearPage(y), p); err != nil {
+			return err
+		}
+	}
+	return s.add(DATES, page{Title: "dates", Sections: []section{archive}})
+}
+
+// each filelist composed by um cat as one long page, with the titles kept.
+func (s *site) compositions(ctx context.Context, stderr io.Writer) error {
+	for _, l := range slices.Sorted(maps.Keys(s.lists)) {
+		path := listPage(l)
+		files := s.lists[l]
+		p := page{Title: strings.TrimSuffix(l, tag.LIST_EXT), Meta: []link{{Text: l}}}
+		if len(files) > 0 {
+			out := bytes.Buffer{}
+			args := []string{pipe.STDIN, "--keep-title"}
+			if err := cat.Run(ctx, args, strings.NewReader(strings.Join(files, pipe.Newline)), &out, stderr); err != nil {
+				return err
+			}
+			r := root(path)
+			p.Body = serve.Render(out.String(), func(f string) string { return r + filePage(f) })
+		}
+		p.Sections = append(p.Sections, section{"files", fileLinks(s.find(files))})
+		if err := s.add(path, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the .um filelists in the current directory, each with only the files of the collection, so that a
+// composition never links to a page the site doesn't have.
+func readLists(entries []tag.Entry) (map[string][]string, error) {
+	known := tag.Set{}
+	for _, e := range entries {
+		known.Add(e.Filename())
+	}
+	names, err := filepath.Glob("*" + tag.LIST_EXT)
+	if err != nil {
+		return nil, err
+	}
+	lists := map[string][]string{}
+	for _, l := range names {
+		lines, err := pipe.FileListSplit(l)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{}
+		for _, f := range lines {
+			if known[f] {
+				files = append(files, f)
+			}
+		}
+		lists[l] = files
+	}
+	return lists, nil
+}
+
+// writes the pages under out, printing each path written. a page already as it would be written is
+// left alone, so that only what changed is touched. then prunes the pages the last build wrote and
+// this one didn't, and records this one's in the manifest.
+func write(out string, pp map[string][]byte, stdout io.Writer) error {
+	previous, err := readManifest(out)
+	if err != nil {
+		return err
+	}
+	for _, path := range slices.Sorted(maps.Keys(pp)) {
+		f := filepath.Join(out, filepath.FromSlash(path))
+		if old, err := os.ReadFile(f); err == nil && bytes.Equal(old, pp[path]) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(f), 0775); err != nil {
+			return fmt.Errorf("%w: %w", cmd.ErrIO, err)
+		}
+		if err := pipe.WriteFile(f, pp[path]); err != nil {
+			return fmt.Errorf("%w: %w", cmd.ErrIO, err)
+		}
+		fmt.Fprintln(stdout, f)
+	}
+	if err := prune(out, previous, pp); err != nil {
+		return err
+	}
+	return writeManifest(out, pp)
+}
+
+// the pages the last build wrote under out, from its manifest. none if there is no manifest yet.
+func readManifest(out string) ([]string, error) {
+	dat, err := os.ReadFile(filepath.Join(out, MANIFEST))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", cmd.ErrIO, err)
+	}
+	return pipe.Split(string(dat), pipe.Newline), nil
+}
+
+func writeManifest(out string, pp map[string][]byte) error {
+	manifest := []byte(strings.Join(slices.Sorted(maps.Keys(pp)), pipe.Newline) + pipe.Newline)
+	f := filepath.Join(out, MANIFEST)
+	if old, err := os.ReadFile(f); err == nil && bytes.Equal(old, manifest) {
+		return nil
+	}
+	if err := pipe.WriteFile(f, manifest); err != nil {
+		return fmt.Errorf("%w: %w", cmd.ErrIO, err)
+	}
+	return nil
+}
+
+// removes the pages of the previous build which this one didn't generate, such as those of a file or
+// tag since removed, and then any directory they leave empty. only what a build wrote is removed, so
+// out may hold other files too.
+func prune(out string, previous []string, pp map[string][]byte) error {
+	for _, path := range previous {
+		// NOTE: the manifest is only a file, so nothing it names outside out is touched:
+		if _, ok := pp[path]; ok || !filepath.IsLocal(filepath.FromSlash(path)) {
+			continue
+		}
+		f := filepath.Join(out, filepath.FromSlash(path))
+		if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %w", cmd.ErrIO, err)
+		}
+		for d := filepath.Dir(f); d != filepath.Clean(out); d = filepath.Dir(d) {
+			if entries, err := os.ReadDir(d); err != nil || len(entries) > 0 || os.Remove(d) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	// BORK: by hand for now:
+	if !opts.Out.IsSet() {
+		return help.HelpRequired("[out]")
+	}
+
+	entries, err := tag.EntriesGlobOrStdin(nil, last.GLOB, pipe.Newline)
+	if err != nil {
+		return err
+	}
+	lists, err := readLists(entries)
+	if err != nil {
+		return err
+	}
+	s := newSite(opts.Title.Val, entries, lists)
+	for _, build := range []func() error{s.index, s.files, s.tags, s.dates} {
+		if err := build(); err != nil {
+			return err
+		}
+	}
+	if err := s.compositions(ctx, stderr); err != nil {
+		return err
+	}
+	return write(opts.Out.Val, s.pages, stdout)
+}
@@ -0,0 +1,179 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+)
+
+// the collection shared by the tests of serve, site and watch.
+const (
+	COLLECTION = "../testdata/collection"
+	CONFIG     = "config.toml"
+)
+
+// the shared collection, copied into a temporary directory with its config in effect.
+func collection(t *testing.T) {
+	src, err := filepath.Abs(COLLECTION)
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	assert.NoError(t, os.CopyFS(dir, os.DirFS(src)))
+	t.Chdir(dir)
+	t.Setenv(config.ENV, filepath.Join(dir, CONFIG))
+}
+
+// the site under out, by path.
+func readSite(t *testing.T, out string) map[string]string {
+	site := map[string]string{}
+	err := filepath.WalkDir(out, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == MANIFEST {
+			return err
+		}
+		dat, err := os.ReadFile(p)
+		rel, _ := filepath.Rel(out, p)
+		site[filepath.ToSlash(rel)] = string(dat)
+		return err
+	})
+	assert.NoError(t, err)
+	return site
+}
+
+func TestRun(t *testing.T) {
+	collection(t)
+	stdout := bytes.Buffer{}
+	assert.NoError(t, Run(context.Background(), []string{"out", "--title", "notes"}, nil, &stdout, io.Discard))
+
+	site := readSite(t, "out")
+	want := []string{
+		"01.foo.html", "02.bar.html", "03.baz.html", "04.html",
+		"dates.html", "dates/2024.html", "dates/2025.html",
+		"index.html", "lists/reading.html", "style.css",
+		"tags.html", "tags/bar.html", "tags/foo.html", "tags/science.html", "tags/science/physics.html",
+	}
+	written := []string{}
+	for _, p := range want {
+		assert.Contains(t, site, p)
+		written = append(written, filepath.Join("out", p))
+	}
+	assert.Len(t, site, len(want))
+	assert.Equal(t, written, strings.Fields(stdout.String()))
+
+	// the header, the body with its markup escaped and its file links relative, and the backlinks:
+	assert.Contains(t, site["01.foo.html"], `<title>One · notes</title>`)
+	assert.Contains(t, site["01.foo.html"], `<p class="meta">01.foo.md · <a href="dates/2024.html">2024.09.25</a></p>`)
+	assert.Contains(t, site["01.foo.html"], `<a href="tags/science/physics.html">&#43; science/physics</a>`)
+	assert.Contains(t, site["01.foo.html"], "<h2>linked from</h2>\n<ul class=\"files\">\n<li><a href=\"02.bar.html\">Two &lt;b&gt;</a>")
+	assert.Contains(t, site["02.bar.html"], `<li><a href="01.foo.html">01.foo.md</a></li>`)
+	assert.Contains(t, site["02.bar.html"], `&lt;script&gt;alert(1)&lt;/script&gt;`)
+	assert.NotContains(t, site["02.bar.html"], `<script>`)
+	assert.NotContains(t, site["04.html"], "linked from")
+
+	// a parent tag has its children's files, and links to them:
+	assert.Contains(t, site["tags/science.html"], `<a href="../tags/science/physics.html">science/physics</a> <span class="meta">2 files</span>`)
+	assert.Contains(t, site["tags/science.html"], `<a href="../01.foo.html">One</a>`)
+	assert.Contains(t, site["tags/science.html"], `<a href="../04.html">Undated</a>`)
+	assert.Contains(t, site["tags/science/physics.html"], `<link rel="stylesheet" href="../../style.css">`)
+	assert.Contains(t, site["tags/science/physics.html"], `<p class="meta"><a href="../../tags/science.html">science</a></p>`)
+	assert.Contains(t, site["tags.html"], `<a href="tags/science.html">science</a> <span class="meta">3 files</span>`)
+
+	// undated files are in no archive:
+	assert.Contains(t, site["dates.html"], `<a href="dates/2024.html">2024</a> <span class="meta">2 files</span>`)
+	assert.Contains(t, site["dates/2024.html"], "<h2>2024.09</h2>\n<ul class=\"files\">\n<li><a href=\"../01.foo.html\">One</a>")
+	assert.Contains(t, site["dates/2024.html"], "<h2>2024.10</h2>")
+	assert.NotContains(t, site["dates/2025.html"], "Undated")
+
+	// a composition has only the files of the collection, as um cat composes them:
+	assert.Contains(t, site["index.html"], `<a href="lists/reading.html">reading.um</a> <span class="meta">2 files</span>`)
+	assert.Contains(t, site["lists/reading.html"], "<h1>Three</h1>\n<p>Last, after <a href=\"../01.foo.html\">one</a>.</p>\n<hr>\n<h1>One</h1>")
+	assert.NotContains(t, site["lists/reading.html"], "secret")
+}
+
+func TestRunDeterministic(t *testing.T) {
+	collection(t)
+	assert.NoError(t, Run(context.Background(), []string{"a"}, nil, io.Discard, io.Discard))
+	assert.NoError(t, Run(context.Background(), []string{"b"}, nil, io.Discard, io.Discard))
+	assert.Equal(t, readSite(t, "a"), readSite(t, "b"))
+
+	// nothing changed, so nothing is written:
+	stdout := bytes.Buffer{}
+	assert.NoError(t, Run(context.Background(), []string{"a"}, nil, &stdout, io.Discard))
+	assert.Empty(t, stdout.String())
+
+	assert.NoError(t, os.WriteFile("03.baz.md", []byte("# Three, again\n: 2025.01.02\n+ science\n"), 0664))
+	assert.NoError(t, Run(context.Background(), []string{"a"}, nil, &stdout, io.Discard))
+	assert.Contains(t, stdout.String(), filepath.Join("a", "03.baz.html"))
+	assert.NotContains(t, stdout.String(), filepath.Join("a", "02.bar.html"))
+
+	// the pages of a removed file and tag go, but pages the build never wrote stay:
+	assert.NoError(t, os.WriteFile(filepath.Join("a", "about.html"), []byte("about"), 0664))
+	assert.NoError(t, os.MkdirAll(filepath.Join("a", "docs"), 0775))
+	assert.NoError(t, os.WriteFile(filepath.Join("a", "docs", "manual.html"), []byte("manual"), 0664))
+	assert.NoError(t, os.Remove("02.bar.md"))
+	assert.NoError(t, Run(context.Background(), []string{"a"}, nil, io.Discard, io.Discard))
+	assert.NoError(t, Run(context.Background(), []string{"c"}, nil, io.Discard, io.Discard))
+	site := readSite(t, "a")
+	assert.NotContains(t, site, "02.bar.html")
+	assert.NotContains(t, site, "tags/bar.html")
+	assert.Equal(t, "about", site["about.html"])
+	assert.Equal(t, "manual", site["docs/manual.html"])
+	delete(site, "about.html")
+	delete(site, "docs/manual.html")
+	assert.Equal(t, readSite(t, "c"), site)
+}
+
+func TestPrune(t *testing.T) {
+	out := t.TempDir()
+	for _, p := range []string{"index.html", "style.css", "tags/science/physics.html", "about.html"} {
+		f := filepath.Join(out, filepath.FromSlash(p))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(f), 0775))
+		assert.NoError(t, os.WriteFile(f, nil, 0664))
+	}
+	previous := []string{"index.html", "style.css", "tags/science/physics.html", "../secret.html"}
+	assert.NoError(t, prune(out, previous, map[string][]byte{"index.html": {}}))
+	// only what the previous build wrote goes, and a directory it leaves empty goes too:
+	assert.Equal(t, map[string]string{"index.html": "", "about.html": ""}, readSite(t, out))
+	assert.NoDirExists(t, filepath.Join(out, "tags"))
+}
+
+func TestRunRequired(t *testing.T) {
+	collection(t)
+	err := Run(context.Background(), nil, nil, io.Discard, io.Discard)
+	assert.ErrorIs(t, err, cmd.ErrUsage)
+}
+
+func TestPaths(t *testing.T) {
+	assert.Equal(t, "0421.html", filePage("0421.md"))
+	assert.Equal(t, "01.foo.html", filePage("01.foo.md"))
+	assert.Equal(t, "lists/reading.html", listPage("reading.um"))
+
+	cases := []struct {
+		tag  string
+		page string
+		ok   bool
+	}{
+		{"science", "tags/science.html", true},
+		{"science/physics", "tags/science/physics.html", true},
+		{"../science", "", false},
+		{"science//physics", "", false},
+		{"/science", "", false},
+	}
+	for _, tc := range cases {
+		page, ok := tagPage(tc.tag)
+		assert.Equal(t, tc.ok, ok, tc.tag)
+		if ok {
+			assert.Equal(t, tc.page, page)
+			assert.Equal(t, strings.Repeat("../", strings.Count(tc.tag, "/")+1), root(page))
+		}
+	}
+	assert.Equal(t, "", root(INDEX))
+}
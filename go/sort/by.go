@@ -0,0 +1,78 @@
+package sort
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
+)
+
+// intrinsic orderings read from the um header.
+type Metadata string
+
+const (
+	BY_DATE   Metadata = "date"
+	BY_NUMBER Metadata = "number"
+	BY_TITLE  Metadata = "title"
+	BY_WORDS  Metadata = "words"
+	BY_TAGS   Metadata = "tags"
+)
+
+// a filelist line with the value it sorts by. computed once up front rather than in every comparison,
+// since word counts scan the whole file.
+type item struct {
+	line  string
+	rank  int64
+	title string
+}
+
+func rankBy(line string, e tag.Entry, by Metadata) item {
+	it := item{line: line}
+	switch by {
+	case BY_DATE:
+		it.rank = e.Date().Unix()
+	case BY_NUMBER:
+		// unnumbered files sort first:
+		it.rank, _ = rankNumber(line)
+	case BY_TITLE:
+		it.title = e.Title()
+	case BY_WORDS:
+		it.rank = int64(e.Words())
+	case BY_TAGS:
+		it.rank = int64(len(e.Tags()))
+	}
+	return it
+}
+
+// orders the filelist by header metadata. ties keep their incoming order.
+func sortBy(sslice []string, by Metadata, reverse bool) (string, error) {
+	items := make([]item, 0, len(sslice))
+	for _, l := range sslice {
+		if l == "" {
+			continue
+		}
+		e, err := tag.ParseFile(l)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, rankBy(l, e, by))
+	}
+	slices.SortStableFunc(items, func(a, b item) int {
+		c := cmp.Compare(a.rank, b.rank)
+		if by == BY_TITLE {
+			c = strings.Compare(a.title, b.title)
+		}
+		if reverse {
+			return -c
+		}
+		return c
+	})
+
+	oslice := make([]string, len(items))
+	for i, it := range items {
+		oslice[i] = it.line
+	}
+	return strings.Join(oslice, pipe.Newline) + pipe.Newline, nil
+}
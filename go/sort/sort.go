@@ -1,104 +1,259 @@
 package sort
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"io"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/brtholomy/um/go/cmd"
 	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/next"
 	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
 )
 
 const (
 	CMD     = cmd.Sort
-	SUMMARY = "sort a filelist using a provided key filelist"
+	SUMMARY = "sort a filelist using a provided key filelist, or by header metadata"
+)
+
+// where entries new to the key are placed.
+type Strategy string
+
+const (
+	APPEND Strategy = "append"
+	NUMBER Strategy = "number"
+	DATE   Strategy = "date"
 )
 
 type options struct {
-	Filelist flags.Glob
-	Key      flags.String
-	Write    flags.Bool
-	Help     flags.Bool
+	Filelist     flags.Glob
+	Key          flags.String
+	Merge        flags.String
+	Comment      flags.Bool
+	Write        flags.Bool
+	By           flags.String
+	Reverse      flags.Bool
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
 }
 
 func initOpts() options {
 	return options{
-		flags.Glob{nil, ".md filelist. accepts multiple. reads from stdin if not provided"},
-		flags.String{"--key", "-k", "", "path to sort key"},
-		flags.Bool{"--write", "-w", false, "write sorted list back to --key file"},
-		flags.Bool{"--help", "-h", false, "show help"},
+		flags.Glob{nil, ".md filelist. accepts multiple. reads from stdin if not provided, or for -", "zettel"},
+		flags.String{"--key", "-k", "", "path to sort key", "um", nil},
+		flags.String{"--merge", "-m", string(APPEND), "place new entries: append | number | date", "append|number|date", &flags.Default{}},
+		flags.Bool{"--comment", "-c", false, "keep entries missing from the filelist as comments in the key", &flags.Default{}},
+		flags.Bool{"--write", "-w", false, "write sorted list back to --key file", nil},
+		flags.String{"--by", "-o", "", "sort by header instead of key: date | number | title | words | tags", "date|number|title|words|tags", nil},
+		flags.Bool{"--reverse", "-r", false, "reverse the --by order", nil},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
-// record the order of the given filelist.
-func kMap(kslice []string) map[string]int {
-	m := make(map[string]int, 0)
-	for i, l := range kslice {
-		m[l] = i
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// entries the filelist added to or removed from the key.
+type report struct {
+	added   []string
+	removed []string
+}
+
+// to stderr, so that the sorted list can still be piped:
+func (r report) log(w io.Writer) {
+	for _, l := range r.added {
+		fmt.Fprintf(w, "um %s: added: %s\n", CMD, l)
+	}
+	for _, l := range r.removed {
+		fmt.Fprintf(w, "um %s: removed: %s\n", CMD, l)
 	}
-	return m
 }
 
-// write out the provided source slice while respecting the order provided by kmap
-func sort(sslice []string, kmap map[string]int) string {
-	oslice := make([]string, max(len(sslice), len(kmap))+1)
-	for _, l := range sslice {
-		// lines not represented in the key get appended to the end:
-		if _, ok := kmap[l]; !ok {
-			// we don't care that this might leave empty strings between:
-			oslice = append(oslice, l)
+// ranks a filename by its number.
+func rankNumber(l string) (int64, bool) {
+	num, err := next.NumFromLast(filepath.Base(l))
+	if err != nil {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(num, 10, 64)
+	return i, err == nil
+}
+
+// ranks a filename by its header date. memoized since each insertion scans the whole list.
+func rankDate() func(string) (int64, bool) {
+	dates := map[string]int64{}
+	return func(l string) (int64, bool) {
+		if d, ok := dates[l]; ok {
+			return d, d != 0
+		}
+		e, err := tag.ParseFile(l)
+		if err != nil || e.Date().IsZero() {
+			dates[l] = 0
+			return 0, false
+		}
+		dates[l] = e.Date().Unix()
+		return dates[l], true
+	}
+}
+
+// inserts l just after its nearest lesser or equal neighbour by rank, or just before its nearest
+// greater neighbour if it has none. appends when l can't be ranked at all.
+func insertNear(out []string, l string, rank func(string) (int64, bool)) []string {
+	r, ok := rank(l)
+	if !ok {
+		return append(out, l)
+	}
+	below, above := -1, -1
+	var rbelow, rabove int64
+	for i, o := range out {
+		ro, ok := rank(o)
+		if !ok {
 			continue
 		}
-		// NOTE: raison d'etre of this whole thang:
-		// if present in the key, respect the order:
-		oslice[kmap[l]] = l
+		if ro <= r && (below < 0 || ro >= rbelow) {
+			below, rbelow = i, ro
+		}
+		if ro > r && (above < 0 || ro < rabove) {
+			above, rabove = i, ro
+		}
+	}
+	switch {
+	case below >= 0:
+		return slices.Insert(out, below+1, l)
+	case above >= 0:
+		return slices.Insert(out, above, l)
 	}
-	// delete empties and add a final newline
-	oslice = slices.DeleteFunc(oslice, func(e string) bool { return e == "" })
-	return strings.Join(oslice, pipe.Newline) + pipe.Newline
+	return append(out, l)
 }
 
-func write(file string, content string) {
-	if err := os.WriteFile(file, []byte(content), 0664); err != nil {
-		log.Fatalf("um %s: error writing file: %s\n%s", CMD, file, err)
+// write out the provided source slice while respecting the order of the key.
+//
+// NOTE: raison d'etre of this whole thang: lines present in the key keep their position, and only new
+// lines are placed according to the merge strategy.
+//
+// each key line claims one occurrence of the same source line, so duplicates on either side are
+// matched one to one and the result is stable. blank lines and comments in the key stay in place.
+func sort(sslice []string, kslice []string, opts options) (string, report) {
+	r := report{}
+	// occurrences of each source line not yet claimed by the key:
+	counts := make(map[string]int, len(sslice))
+	for _, l := range sslice {
+		if l != "" {
+			counts[l]++
+		}
+	}
+
+	oslice := make([]string, 0, len(sslice)+len(kslice))
+	for _, l := range kslice {
+		// a line commented out by a previous run is restored if it has returned:
+		if uncommented, ok := strings.CutPrefix(l, pipe.Comment); ok && counts[uncommented] > 0 {
+			l = uncommented
+		}
+		switch {
+		case counts[l] > 0:
+			counts[l]--
+			oslice = append(oslice, l)
+		case l == "" || strings.HasPrefix(l, pipe.Comment):
+			oslice = append(oslice, l)
+		default:
+			r.removed = append(r.removed, l)
+			if opts.Comment.IsSet() {
+				oslice = append(oslice, pipe.Comment+l)
+			}
+		}
+	}
+
+	var rank func(string) (int64, bool)
+	switch Strategy(opts.Merge.Val) {
+	case NUMBER:
+		rank = rankNumber
+	case DATE:
+		rank = rankDate()
+	}
+	// whatever the key didn't claim is new, in source order:
+	for _, l := range sslice {
+		if counts[l] == 0 {
+			continue
+		}
+		counts[l]--
+		r.added = append(r.added, l)
+		if rank == nil {
+			oslice = append(oslice, l)
+			continue
+		}
+		oslice = insertNear(oslice, l, rank)
 	}
+	if len(oslice) == 0 {
+		return "", r
+	}
+	return strings.Join(oslice, pipe.Newline) + pipe.Newline, r
 }
 
-func Sort(args []string) {
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	opts := initOpts()
 	help := flags.NewHelpError(CMD, SUMMARY)
 	if err := flags.ParseArgs(help, args, &opts); err != nil {
-		if errors.As(err, &help) {
-			fmt.Println(help)
-			return
-		}
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
 	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
 	// BORK: by hand for now:
-	if !opts.Key.IsSet() {
-		fmt.Println(help.HelpRequired(opts.Key.Long))
-		return
+	if opts.By.IsSet() && opts.Key.IsSet() {
+		return help.HelpExclusive(opts.By.Long, opts.Key.Long)
+	}
+	// there's no key to write back to:
+	if opts.By.IsSet() && opts.Write.Val {
+		return help.HelpExclusive(opts.By.Long, opts.Write.Long)
+	}
+	if !opts.Key.IsSet() && !opts.By.IsSet() {
+		return help.HelpRequired(opts.Key.Long)
+	}
+	if !slices.Contains([]Strategy{APPEND, NUMBER, DATE}, Strategy(opts.Merge.Val)) {
+		return help.HelpInvalidArg(opts.Merge.Val)
+	}
+	if opts.By.IsSet() && !slices.Contains([]Metadata{BY_DATE, BY_NUMBER, BY_TITLE, BY_WORDS, BY_TAGS}, Metadata(opts.By.Val)) {
+		return help.HelpInvalidArg(opts.By.Val)
 	}
 
 	// NOTE: um sort expects a list of .md files, in contrast to um cat.
-	sslice, err := pipe.GlobOrStdin(opts.Filelist.Val)
+	sslice, err := pipe.GlobOrStdin(stdin, opts.Filelist.Val, pipe.Sep(opts.Null.Val))
 	if err != nil {
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
+	}
+	if opts.By.IsSet() {
+		out, err := sortBy(sslice, Metadata(opts.By.Val), opts.Reverse.Val)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, out)
+		return nil
 	}
 	kslice, err := pipe.FileListSplit(opts.Key.Val)
 	if err != nil {
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
 	}
-	kmap := kMap(kslice)
-	out := sort(sslice, kmap)
+	out, r := sort(sslice, kslice, opts)
+	r.log(stderr)
 	if opts.Write.IsSet() {
-		write(opts.Key.Val, out)
-	} else {
-		// to stdout
-		fmt.Print(out)
+		if err := pipe.WriteFile(opts.Key.Val, []byte(out)); err != nil {
+			return fmt.Errorf("error writing file: %s: %w", opts.Key.Val, err)
+		}
+		return nil
 	}
+	fmt.Fprint(stdout, out)
+	return nil
 }
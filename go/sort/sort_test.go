@@ -0,0 +1,123 @@
+package sort
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_PATTERN string = "../tag/testdata/*.md"
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, pipe.Newline), pipe.Newline)
+}
+
+func TestSort(t *testing.T) {
+	cases := []struct {
+		name    string
+		source  string
+		key     string
+		merge   Strategy
+		comment bool
+		want    string
+		added   []string
+		removed []string
+	}{
+		{"same", "01.md\n02.md\n", "02.md\n01.md\n", APPEND, false, "02.md\n01.md\n", nil, nil},
+		{"appended", "01.md\n02.md\n03.md\n", "02.md\n01.md\n", APPEND, false, "02.md\n01.md\n03.md\n", []string{"03.md"}, nil},
+		{"removed", "01.md\n", "02.md\n01.md\n", APPEND, false, "01.md\n", nil, []string{"02.md"}},
+		{"commented", "01.md\n", "02.md\n01.md\n", APPEND, true, "# 02.md\n01.md\n", nil, []string{"02.md"}},
+		{"restored", "01.md\n02.md\n", "# 02.md\n01.md\n", APPEND, false, "02.md\n01.md\n", nil, nil},
+		{"comments and blanks", "01.md\n02.md\n", "# part one\n02.md\n\n# part two\n01.md\n", APPEND, false, "# part one\n02.md\n\n# part two\n01.md\n", nil, nil},
+		{"duplicate source", "01.md\n02.md\n01.md\n", "02.md\n01.md\n", APPEND, false, "02.md\n01.md\n01.md\n", []string{"01.md"}, nil},
+		{"duplicate key", "01.md\n02.md\n", "01.md\n02.md\n01.md\n", APPEND, false, "01.md\n02.md\n", nil, []string{"01.md"}},
+		{"number", "01.md\n05.md\n10.md\n", "10.md\n01.md\n", NUMBER, false, "10.md\n01.md\n05.md\n", []string{"05.md"}, nil},
+		{"number first", "01.md\n05.md\n10.md\n", "10.md\n05.md\n", NUMBER, false, "10.md\n01.md\n05.md\n", []string{"01.md"}, nil},
+		{"empty", "", "", APPEND, false, "", nil, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := initOpts()
+			opts.Merge.Val = string(tc.merge)
+			opts.Comment.Val = tc.comment
+			got, r := sort(lines(tc.source), lines(tc.key), opts)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.added, r.added)
+			assert.Equal(t, tc.removed, r.removed)
+		})
+	}
+}
+
+func TestSortDate(t *testing.T) {
+	files, err := filepath.Glob(TEST_PATTERN)
+	assert.NoError(t, err)
+	opts := initOpts()
+	opts.Merge.Val = string(DATE)
+	// 04 is dated after 01 and 02, but 02 is new:
+	key := []string{files[3], files[0]}
+	got, _ := sort([]string{files[0], files[1], files[3]}, key, opts)
+	assert.Equal(t, strings.Join([]string{files[3], files[0], files[1]}, pipe.Newline)+pipe.Newline, got)
+}
+
+func TestSortBy(t *testing.T) {
+	files, err := filepath.Glob(TEST_PATTERN)
+	assert.NoError(t, err)
+	cases := []struct {
+		by      Metadata
+		reverse bool
+		want    []int
+	}{
+		{BY_NUMBER, true, []int{5, 4, 3, 2, 1, 0}},
+		{BY_DATE, false, []int{0, 1, 2, 3, 4, 5}},
+		{BY_DATE, true, []int{3, 4, 5, 0, 1, 2}},
+		{BY_TAGS, false, []int{5, 3, 4, 0, 1, 2}},
+		{BY_WORDS, true, []int{0, 3, 1, 2, 4, 5}},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.by), func(t *testing.T) {
+			got, err := sortBy(files, tc.by, tc.reverse)
+			assert.NoError(t, err)
+			want := []string{}
+			for _, i := range tc.want {
+				want = append(want, files[i])
+			}
+			assert.Equal(t, strings.Join(want, pipe.Newline)+pipe.Newline, got)
+		})
+	}
+}
+
+func TestSortByExclusive(t *testing.T) {
+	for _, args := range [][]string{
+		{"-o", "date", "--key", "key.um", TEST_PATTERN},
+		{"-o", "date", "--write", TEST_PATTERN},
+	} {
+		err := Run(context.Background(), args, nil, io.Discard, io.Discard)
+		assert.ErrorIs(t, err, cmd.ErrUsage, args)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "key.um")
+	assert.NoError(t, os.WriteFile(f, []byte("01.md\n"), 0600))
+	assert.NoError(t, pipe.WriteFile(f, []byte("02.md\n")))
+
+	dat, err := os.ReadFile(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "02.md\n", string(dat))
+	stat, err := os.Stat(f)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	// no temp files left behind:
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(f), ".key.um.*"))
+	assert.Empty(t, matches)
+}
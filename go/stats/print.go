@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// keeps the first error writing to w, and writes nothing after it, so that a run of Fprint calls
+// needs checking only once at the end.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// the same TOML-like layout as um tag --verbose, with the per period table aligned in columns.
+func printText(w io.Writer, s Stats) error {
+	ew := &errWriter{w: w}
+	fmt.Fprintln(ew, "[summary]")
+	fmt.Fprintf(ew, "%-20s= %d\n", "files", s.Files)
+	fmt.Fprintf(ew, "%-20s= %d\n", "undated", s.Undated)
+	fmt.Fprintf(ew, "%-20s= %d\n", "words", s.Words)
+	fmt.Fprintf(ew, "%-20s= %.1f\n", "average words", s.Average)
+	fmt.Fprintf(ew, "%-20s= %d\n", "median words", s.Median)
+	fmt.Fprintf(ew, "%-20s= %d\n", "tags", s.Tags)
+	fmt.Fprintln(ew)
+
+	fmt.Fprintf(ew, "[%s]\n", s.By)
+	tw := tabwriter.NewWriter(ew, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "period\tfiles\twords\tnew tags\ttotal tags")
+	for _, r := range s.Periods {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", r.Period, r.Files, r.Words, r.NewTags, r.TotalTags)
+	}
+	tw.Flush()
+	fmt.Fprintln(ew)
+
+	fmt.Fprintln(ew, "[tags]")
+	for _, t := range s.Active {
+		fmt.Fprintf(ew, "%-20s= %d\n", t.Tag, t.Files)
+	}
+	fmt.Fprintln(ew)
+
+	fmt.Fprintln(ew, "[streaks]")
+	for _, st := range s.Streaks {
+		fmt.Fprintf(ew, "%-24s= %d\n", st.From+"-"+st.To, st.Days)
+	}
+	return ew.err
+}
+
+func printJSON(w io.Writer, s Stats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// only the per period table, since that's what gets plotted.
+func printCSV(w io.Writer, s Stats) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "files", "words", "new_tags", "total_tags"})
+	for _, r := range s.Periods {
+		cw.Write([]string{r.Period, strconv.Itoa(r.Files), strconv.Itoa(r.Words), strconv.Itoa(r.NewTags), strconv.Itoa(r.TotalTags)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,259 @@
+package stats
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Stats
+	SUMMARY = "report files, words and tags over time, from header dates"
+)
+
+// the span of time files are counted in.
+type Period string
+
+const (
+	DAY   Period = "day"
+	MONTH Period = "month"
+	YEAR  Period = "year"
+)
+
+// period keys in the um date format, cut short. they sort chronologically as strings.
+var layouts = map[Period]string{
+	DAY:   tag.DATE_FORMAT,
+	MONTH: "2006.01",
+	YEAR:  "2006",
+}
+
+type Format string
+
+const (
+	TEXT Format = "text"
+	JSON Format = "json"
+	CSV  Format = "csv"
+)
+
+type options struct {
+	By           flags.String
+	Date         flags.String
+	Top          flags.Int
+	Format       flags.String
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.String{"--by", "-b", string(MONTH), "count per period: day | month | year", "day|month|year", &flags.Default{}},
+		flags.String{"--date", "-d", "", "date range in ISO 8601: YYYY.MM.DD[-YYYY.MM.DD]", "none", nil},
+		flags.Int{"--top", "-n", 10, "number of tags and streaks to list. 0 lists all", "none", &flags.Default{}},
+		flags.String{"--format", "-f", string(TEXT), "text | json | csv. csv is the per period table alone", "text|json|csv", &flags.Default{}},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// one period of the collection's history.
+type Row struct {
+	Period string `json:"period"`
+	Files  int    `json:"files"`
+	Words  int    `json:"words"`
+	// tags first used in this period:
+	NewTags int `json:"new_tags"`
+	// tags used by the end of this period:
+	TotalTags int `json:"total_tags"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Files int    `json:"files"`
+}
+
+// consecutive days with at least one file.
+type Streak struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days int    `json:"days"`
+}
+
+type Stats struct {
+	Files int `json:"files"`
+	// files without a date in their header, left out of periods and streaks:
+	Undated int `json:"undated"`
+	Words   int `json:"words"`
+	// words per file:
+	Average float64 `json:"average_words"`
+	Median  int     `json:"median_words"`
+	Tags    int     `json:"tags"`
+	By      Period  `json:"by"`
+	Periods []Row   `json:"periods"`
+	// most used tags first:
+	Active  []TagCount `json:"active_tags"`
+	Streaks []Streak   `json:"streaks"`
+}
+
+// the first n, or all of them for n <= 0.
+func top[S ~[]E, E any](s S, n int) S {
+	if n <= 0 || n >= len(s) {
+		return s
+	}
+	return s[:n]
+}
+
+func median(words []int) int {
+	if len(words) == 0 {
+		return 0
+	}
+	sorted := slices.Sorted(slices.Values(words))
+	return sorted[(len(sorted)-1)/2]
+}
+
+// rows in chronological order, with tag growth counted from the first period.
+func periods(dated []tag.Entry, by Period) []Row {
+	slices.SortStableFunc(dated, func(a, b tag.Entry) int {
+		return a.Date().Compare(b.Date())
+	})
+	rows := []Row{}
+	seen := map[string]bool{}
+	for _, e := range dated {
+		p := e.Date().Format(layouts[by])
+		if len(rows) == 0 || rows[len(rows)-1].Period != p {
+			rows = append(rows, Row{Period: p, TotalTags: len(seen)})
+		}
+		r := &rows[len(rows)-1]
+		r.Files++
+		r.Words += e.Words()
+		for _, t := range e.Tags() {
+			if !seen[t] {
+				seen[t] = true
+				r.NewTags++
+				r.TotalTags++
+			}
+		}
+	}
+	return rows
+}
+
+// tags by the number of files using them, ties in name order.
+func active(entries []tag.Entry) []TagCount {
+	counts := map[string]int{}
+	for _, e := range entries {
+		for _, t := range e.Tags() {
+			counts[t]++
+		}
+	}
+	tc := []TagCount{}
+	for _, t := range slices.Sorted(maps.Keys(counts)) {
+		tc = append(tc, TagCount{t, counts[t]})
+	}
+	slices.SortStableFunc(tc, func(a, b TagCount) int {
+		return cmp.Compare(b.Files, a.Files)
+	})
+	return tc
+}
+
+// the longest runs of consecutive days first, ties in date order.
+func streaks(dated []tag.Entry) []Streak {
+	days := map[time.Time]bool{}
+	for _, e := range dated {
+		// NOTE: header dates have no time of day:
+		days[e.Date()] = true
+	}
+	sorted := slices.SortedFunc(maps.Keys(days), time.Time.Compare)
+	ss := []Streak{}
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j].AddDate(0, 0, 1).Equal(sorted[j+1]) {
+			j++
+		}
+		ss = append(ss, Streak{sorted[i].Format(tag.DATE_FORMAT), sorted[j].Format(tag.DATE_FORMAT), j - i + 1})
+		i = j + 1
+	}
+	slices.SortStableFunc(ss, func(a, b Streak) int {
+		return cmp.Compare(b.Days, a.Days)
+	})
+	return ss
+}
+
+func stats(entries []tag.Entry, by Period, n int) Stats {
+	s := Stats{Files: len(entries), By: by}
+	dated := make([]tag.Entry, 0, len(entries))
+	words := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if e.Date().IsZero() {
+			s.Undated++
+		} else {
+			dated = append(dated, e)
+		}
+		words = append(words, e.Words())
+		s.Words += e.Words()
+	}
+	if s.Files > 0 {
+		s.Average = float64(s.Words) / float64(s.Files)
+	}
+	s.Median = median(words)
+	s.Periods = periods(dated, by)
+	tc := active(entries)
+	s.Tags = len(tc)
+	s.Active = top(tc, n)
+	s.Streaks = top(streaks(dated), n)
+	return s
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
+	// BORK: by hand for now:
+	if _, ok := layouts[Period(opts.By.Val)]; !ok {
+		return help.HelpInvalidValue(opts.By.Long, opts.By.Val)
+	}
+	if !slices.Contains([]Format{TEXT, JSON, CSV}, Format(opts.Format.Val)) {
+		return help.HelpInvalidValue(opts.Format.Long, opts.Format.Val)
+	}
+
+	entries, err := tag.EntriesGlobOrStdin(stdin, last.GLOB, pipe.Sep(opts.Null.Val))
+	if err != nil {
+		return err
+	}
+	if opts.Date.IsSet() {
+		entries, err = tag.DateRange(entries, opts.Date.Val)
+		if err != nil {
+			return err
+		}
+	}
+	s := stats(entries, Period(opts.By.Val), opts.Top.Val)
+	switch Format(opts.Format.Val) {
+	case JSON:
+		return printJSON(stdout, s)
+	case CSV:
+		return printCSV(stdout, s)
+	}
+	return printText(stdout, s)
+}
@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/brtholomy/um/go/tag"
+	"github.com/stretchr/testify/assert"
+)
+
+// a small collection in testdata, spanning a month boundary, with one undated file:
+const TEST_PATTERN string = "./testdata/*.md"
+
+func testEntries(t *testing.T) []tag.Entry {
+	entries, err := tag.EntriesGlobOrStdin(nil, TEST_PATTERN, "\n")
+	assert.NoError(t, err)
+	return entries
+}
+
+func TestStats(t *testing.T) {
+	s := stats(testEntries(t), MONTH, 10)
+	assert.Equal(t, 6, s.Files)
+	assert.Equal(t, 1, s.Undated)
+	assert.Equal(t, 16, s.Words)
+	assert.InDelta(t, 2.67, s.Average, 0.01)
+	assert.Equal(t, 2, s.Median)
+	assert.Equal(t, 3, s.Tags)
+	assert.Equal(t, []Row{
+		{"2024.01", 2, 4, 2, 2},
+		{"2024.02", 3, 11, 1, 3},
+	}, s.Periods)
+	assert.Equal(t, []TagCount{{"phil", 3}, {"sci", 2}, {"art", 1}}, s.Active)
+	assert.Equal(t, []Streak{{"2024.01.30", "2024.02.01", 3}, {"2024.02.05", "2024.02.05", 1}}, s.Streaks)
+}
+
+func TestStatsBy(t *testing.T) {
+	entries := testEntries(t)
+	assert.Equal(t, []Row{{"2024", 5, 15, 3, 3}}, stats(entries, YEAR, 10).Periods)
+
+	days := stats(entries, DAY, 1)
+	assert.Len(t, days.Periods, 4)
+	assert.Equal(t, Row{"2024.02.05", 2, 9, 1, 3}, days.Periods[3])
+	// --top applies to tags and streaks:
+	assert.Len(t, days.Active, 1)
+	assert.Len(t, days.Streaks, 1)
+}
+
+func TestPrint(t *testing.T) {
+	s := stats(testEntries(t), MONTH, 10)
+
+	w := bytes.Buffer{}
+	assert.NoError(t, printCSV(&w, s))
+	assert.Equal(t, "period,files,words,new_tags,total_tags\n2024.01,2,4,2,2\n2024.02,3,11,1,3\n", w.String())
+
+	w.Reset()
+	assert.NoError(t, printJSON(&w, s))
+	got := Stats{}
+	assert.NoError(t, json.Unmarshal(w.Bytes(), &got))
+	assert.Equal(t, s, got)
+
+	w.Reset()
+	assert.NoError(t, printText(&w, s))
+	assert.Contains(t, w.String(), "[month]\nperiod   files  words  new tags  total tags\n2024.01  2      4      2         2\n")
+	assert.Contains(t, w.String(), "2024.01.30-2024.02.01   = 3\n")
+}
+
+// accepts n bytes, fails the write going past them, and accepts everything after. so only the first
+// error tells of the lost output.
+type failWriter struct {
+	n      int
+	failed bool
+}
+
+var errFull = errors.New("full")
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	if !f.failed && len(p) > f.n {
+		f.failed = true
+		return f.n, errFull
+	}
+	f.n -= len(p)
+	return len(p), nil
+}
+
+func TestPrintTextError(t *testing.T) {
+	s := stats(testEntries(t), MONTH, 10)
+	w := bytes.Buffer{}
+	assert.NoError(t, printText(&w, s))
+
+	// failing at the start, within the table, and at the very end:
+	for _, n := range []int{0, 200, w.Len() - 1} {
+		assert.ErrorIs(t, printText(&failWriter{n: n}, s), errFull, n)
+	}
+}
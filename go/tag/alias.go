@@ -0,0 +1,189 @@
+package tag
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+)
+
+const (
+	// looked for in the current directory, which is the collection. read after the config, so that
+	// it wins:
+	ALIAS_FILE = ".umtags"
+	// alias = canonical, in either file:
+	ALIAS_SECTION = "aliases"
+	// a top level key, in either file:
+	FOLD_KEY = "fold-case"
+)
+
+// maps tags to their canonical names as they're parsed, so that queries and counts merge synonyms.
+type Aliases struct {
+	names map[string]string
+	// lowercase every tag before looking up its alias:
+	fold bool
+}
+
+// reads [aliases] and fold-case from the config and then ALIAS_FILE. both missing is no aliases.
+func LoadAliases() (Aliases, error) {
+	a := Aliases{names: map[string]string{}}
+	conf, err := config.Load()
+	if err != nil {
+		return a, err
+	}
+	fromConfig, err := a.merge(config.Path(), conf)
+	if err != nil {
+		return a, err
+	}
+	dat, err := os.ReadFile(ALIAS_FILE)
+	if errors.Is(err, fs.ErrNotExist) {
+		dat, err = nil, nil
+	}
+	if err != nil {
+		return a, fmt.Errorf("error opening aliases: %w", err)
+	}
+	conf, err = config.Parse(ALIAS_FILE, string(dat))
+	if err != nil {
+		return a, err
+	}
+	fromFile, err := a.merge(ALIAS_FILE, conf)
+	if err != nil {
+		return a, err
+	}
+	// only once both are read do we know whether to fold. aliases differing only in case then collide,
+	// and the later file wins as it would have unfolded. within a file, the last in sorted order wins:
+	for _, section := range []map[string]string{fromConfig, fromFile} {
+		for _, alias := range slices.Sorted(maps.Keys(section)) {
+			canon := section[alias]
+			if a.fold {
+				alias, canon = strings.ToLower(alias), strings.ToLower(canon)
+			}
+			a.names[alias] = canon
+		}
+	}
+	return a, nil
+}
+
+// reads fold-case, and returns the aliases to be merged once fold-case is settled. name is only used
+// for error messages.
+func (a *Aliases) merge(name string, conf config.Config) (map[string]string, error) {
+	if v, ok := conf.Get("", FOLD_KEY); ok {
+		fold, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: invalid %s: %s", cmd.ErrParse, name, FOLD_KEY, v)
+		}
+		a.fold = fold
+	}
+	return conf.Section(ALIAS_SECTION), nil
+}
+
+// the canonical name of a tag. an alias of a parent renames its descendants too, so with phil =
+// philosophy, phil/ethics is philosophy/ethics. aliases may chain, but not loop.
+func (a Aliases) Canonical(t string) string {
+	if a.fold {
+		t = strings.ToLower(t)
+	}
+	seen := map[string]bool{}
+	for !seen[t] {
+		seen[t] = true
+		for _, p := range append([]string{t}, Ancestors(t)...) {
+			if canon, ok := a.names[p]; ok {
+				t = canon + t[len(p):]
+				break
+			}
+		}
+	}
+	return t
+}
+
+// canonical tags in their original order, without the repeats that merging synonyms makes.
+func (a Aliases) apply(tags []string) []string {
+	if len(a.names) == 0 && !a.fold {
+		return tags
+	}
+	canonical := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if c := a.Canonical(t); !slices.Contains(canonical, c) {
+			canonical = append(canonical, c)
+		}
+	}
+	return canonical
+}
+
+// a pair of tags which are likely the same.
+type suggestion struct {
+	alias string
+	canon string
+	// 0 for a difference of case alone:
+	distance int
+}
+
+// whether two tags differ little enough to be a typo: a single edit in a short tag, two in a long
+// one. very short tags are never near, since most of them would be.
+func near(a, b string) (int, bool) {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la == lb {
+		return 0, true
+	}
+	d := cmd.Distance(la, lb)
+	return d, d <= min(2, min(len([]rune(la)), len([]rune(lb)))/4)
+}
+
+// near-duplicate tags, each suggested as an alias of the one on more files. ties go to the
+// lowercase one, then the first by name. tags are taken in that order, and each is an alias of the
+// first canonical tag it's near, or else canonical itself, so that no alias is suggested for another.
+func suggestAliases(tagmap map[string]Set) []suggestion {
+	cased := func(t string) int {
+		if strings.ToLower(t) == t {
+			return 0
+		}
+		return 1
+	}
+	names := slices.SortedFunc(maps.Keys(tagmap), func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(len(tagmap[b]), len(tagmap[a])),
+			cmp.Compare(cased(a), cased(b)),
+			strings.Compare(a, b),
+		)
+	})
+	canonical := []string{}
+	suggestions := []suggestion{}
+	for _, t := range names {
+		i := slices.IndexFunc(canonical, func(c string) bool {
+			_, ok := near(t, c)
+			return ok
+		})
+		if i < 0 {
+			canonical = append(canonical, t)
+			continue
+		}
+		d, _ := near(t, canonical[i])
+		suggestions = append(suggestions, suggestion{t, canonical[i], d})
+	}
+	slices.SortFunc(suggestions, func(a, b suggestion) int {
+		return cmp.Or(strings.Compare(a.canon, b.canon), strings.Compare(a.alias, b.alias))
+	})
+	return suggestions
+}
+
+// the suggestions as an [aliases] section ready to paste into ALIAS_FILE, each with a comment
+// giving the reason and the files having either tag.
+func printAliases(w io.Writer, tagmap map[string]Set) {
+	fmt.Fprintf(w, "[%s]\n", ALIAS_SECTION)
+	for _, s := range suggestAliases(tagmap) {
+		reason := "case"
+		if s.distance > 0 {
+			reason = fmt.Sprintf("distance %d", s.distance)
+		}
+		fmt.Fprintf(w, "%-20s= %-22q# %s: %d : %d\n", s.alias, s.canon, reason, len(tagmap[s.alias]), len(tagmap[s.canon]))
+	}
+}
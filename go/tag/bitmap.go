@@ -0,0 +1,295 @@
+package tag
+
+import (
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// ids are split by their high bits into containers holding the low ones:
+	CONTAINER_BITS = 16
+	// an array container larger than this would take more room than a bitset, so it becomes one:
+	ARRAY_MAX = 4096
+
+	bitsetWords = 1 << CONTAINER_BITS / 64
+)
+
+// a compressed bitmap of ids, after roaring bitmaps: a sorted array for each sparse container, and a
+// plain bitset for each dense one. the zero Bitmap is empty.
+//
+// only Add changes a Bitmap. the set operations return a new one, sharing nothing with either side.
+type Bitmap struct {
+	// sorted, each with the container of the same index:
+	keys       []uint16
+	containers []container
+}
+
+type container struct {
+	// the low bits in order while sparse:
+	array []uint16
+	// or a bit each once dense:
+	bitset []uint64
+	n      int
+}
+
+func (c *container) has(x uint16) bool {
+	if c.bitset != nil {
+		return c.bitset[x/64]&(1<<(x%64)) != 0
+	}
+	_, ok := slices.BinarySearch(c.array, x)
+	return ok
+}
+
+func (c *container) add(x uint16) {
+	if c.bitset != nil {
+		if !c.has(x) {
+			c.bitset[x/64] |= 1 << (x % 64)
+			c.n++
+		}
+		return
+	}
+	// ids mostly come in order, so appending is the common case:
+	if len(c.array) == 0 || c.array[len(c.array)-1] < x {
+		c.array = append(c.array, x)
+	} else if i, ok := slices.BinarySearch(c.array, x); !ok {
+		c.array = slices.Insert(c.array, i, x)
+	} else {
+		return
+	}
+	c.n++
+	if c.n > ARRAY_MAX {
+		c.toBitset()
+	}
+}
+
+func (c *container) toBitset() {
+	bs := make([]uint64, bitsetWords)
+	for _, x := range c.array {
+		bs[x/64] |= 1 << (x % 64)
+	}
+	c.bitset, c.array = bs, nil
+}
+
+// counts the bitset, and turns it back into an array if it has become sparse.
+func (c *container) settle() {
+	c.n = 0
+	for _, w := range c.bitset {
+		c.n += bits.OnesCount64(w)
+	}
+	if c.n > ARRAY_MAX {
+		return
+	}
+	arr := make([]uint16, 0, c.n)
+	for x := range c.all() {
+		arr = append(arr, x)
+	}
+	c.array, c.bitset = arr, nil
+}
+
+func (c *container) clone() container {
+	return container{slices.Clone(c.array), slices.Clone(c.bitset), c.n}
+}
+
+func (c *container) all() iter.Seq[uint16] {
+	return func(yield func(uint16) bool) {
+		if c.bitset == nil {
+			for _, x := range c.array {
+				if !yield(x) {
+					return
+				}
+			}
+			return
+		}
+		for i, w := range c.bitset {
+			for w != 0 {
+				if !yield(uint16(i*64 + bits.TrailingZeros64(w))) {
+					return
+				}
+				// clear the lowest bit:
+				w &= w - 1
+			}
+		}
+	}
+}
+
+// a ∪ b
+func or(a, b *container) container {
+	if a.bitset == nil && b.bitset == nil {
+		arr := make([]uint16, 0, len(a.array)+len(b.array))
+		i, j := 0, 0
+		for i < len(a.array) && j < len(b.array) {
+			switch {
+			case a.array[i] < b.array[j]:
+				arr = append(arr, a.array[i])
+				i++
+			case a.array[i] > b.array[j]:
+				arr = append(arr, b.array[j])
+				j++
+			default:
+				arr = append(arr, a.array[i])
+				i++
+				j++
+			}
+		}
+		arr = append(append(arr, a.array[i:]...), b.array[j:]...)
+		c := container{array: arr, n: len(arr)}
+		if c.n > ARRAY_MAX {
+			c.toBitset()
+		}
+		return c
+	}
+	c := container{bitset: make([]uint64, bitsetWords)}
+	for _, src := range []*container{a, b} {
+		if src.bitset != nil {
+			for i, w := range src.bitset {
+				c.bitset[i] |= w
+			}
+			continue
+		}
+		for _, x := range src.array {
+			c.bitset[x/64] |= 1 << (x % 64)
+		}
+	}
+	c.settle()
+	return c
+}
+
+// a ∩ b
+func and(a, b *container) container {
+	if a.bitset != nil && b.bitset != nil {
+		c := container{bitset: make([]uint64, bitsetWords)}
+		for i := range c.bitset {
+			c.bitset[i] = a.bitset[i] & b.bitset[i]
+		}
+		c.settle()
+		return c
+	}
+	// walk the array, looking each member up in the other:
+	if a.bitset != nil {
+		a, b = b, a
+	}
+	arr := []uint16{}
+	for _, x := range a.array {
+		if b.has(x) {
+			arr = append(arr, x)
+		}
+	}
+	return container{array: arr, n: len(arr)}
+}
+
+// a − b
+func andNot(a, b *container) container {
+	if a.bitset != nil {
+		c := container{bitset: slices.Clone(a.bitset)}
+		if b.bitset != nil {
+			for i, w := range b.bitset {
+				c.bitset[i] &^= w
+			}
+		} else {
+			for _, x := range b.array {
+				c.bitset[x/64] &^= 1 << (x % 64)
+			}
+		}
+		c.settle()
+		return c
+	}
+	arr := []uint16{}
+	for _, x := range a.array {
+		if !b.has(x) {
+			arr = append(arr, x)
+		}
+	}
+	return container{array: arr, n: len(arr)}
+}
+
+func split(id uint32) (uint16, uint16) {
+	return uint16(id >> CONTAINER_BITS), uint16(id)
+}
+
+func (b *Bitmap) Add(ids ...uint32) {
+	for _, id := range ids {
+		key, low := split(id)
+		i, ok := slices.BinarySearch(b.keys, key)
+		if !ok {
+			b.keys = slices.Insert(b.keys, i, key)
+			b.containers = slices.Insert(b.containers, i, container{})
+		}
+		b.containers[i].add(low)
+	}
+}
+
+func (b Bitmap) Has(id uint32) bool {
+	key, low := split(id)
+	i, ok := slices.BinarySearch(b.keys, key)
+	return ok && b.containers[i].has(low)
+}
+
+// the number of ids.
+func (b Bitmap) Len() int {
+	n := 0
+	for i := range b.containers {
+		n += b.containers[i].n
+	}
+	return n
+}
+
+// the ids in order.
+func (b Bitmap) All() iter.Seq[uint32] {
+	return func(yield func(uint32) bool) {
+		for i, key := range b.keys {
+			for low := range b.containers[i].all() {
+				if !yield(uint32(key)<<CONTAINER_BITS | uint32(low)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// merges two bitmaps container by container. a container on one side only is kept as it is if onlyA
+// or onlyB says so, and dropped otherwise.
+func combine(a, b Bitmap, op func(x, y *container) container, onlyA, onlyB bool) Bitmap {
+	out := Bitmap{}
+	push := func(key uint16, c container) {
+		if c.n > 0 {
+			out.keys = append(out.keys, key)
+			out.containers = append(out.containers, c)
+		}
+	}
+	i, j := 0, 0
+	for i < len(a.keys) || j < len(b.keys) {
+		switch {
+		case j == len(b.keys) || i < len(a.keys) && a.keys[i] < b.keys[j]:
+			if onlyA {
+				push(a.keys[i], a.containers[i].clone())
+			}
+			i++
+		case i == len(a.keys) || b.keys[j] < a.keys[i]:
+			if onlyB {
+				push(b.keys[j], b.containers[j].clone())
+			}
+			j++
+		default:
+			push(a.keys[i], op(&a.containers[i], &b.containers[j]))
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// b ∪ o
+func (b Bitmap) Or(o Bitmap) Bitmap {
+	return combine(b, o, or, true, true)
+}
+
+// b ∩ o
+func (b Bitmap) And(o Bitmap) Bitmap {
+	return combine(b, o, and, false, false)
+}
+
+// b − o
+func (b Bitmap) AndNot(o Bitmap) Bitmap {
+	return combine(b, o, andNot, true, false)
+}
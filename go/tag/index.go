@@ -0,0 +1,154 @@
+package tag
+
+import (
+	"maps"
+	"slices"
+)
+
+// the entries by dense integer ids, each tag with a Bitmap of the ids of its files, so that the set
+// operations of a query never touch a filename.
+type Index struct {
+	// by file id, in the order of the entries. a file listed twice keeps its first id:
+	entries []Entry
+	// tag names by tag id, sorted:
+	names []string
+	// by tag id:
+	tags []Bitmap
+	// tag ids of each file, by file id:
+	fileTags [][]uint32
+	// every file, tagged or not: what a complement is taken against.
+	all Bitmap
+}
+
+func NewIndex(entries []Entry) *Index {
+	ix := &Index{entries: make([]Entry, 0, len(entries))}
+	seen := make(map[string]bool, len(entries))
+	tagIDs := map[string]uint32{}
+	for _, e := range entries {
+		if seen[e.filename] {
+			continue
+		}
+		seen[e.filename] = true
+		ix.entries = append(ix.entries, e)
+		for _, t := range e.tags {
+			tagIDs[t] = 0
+		}
+	}
+	ix.names = slices.Sorted(maps.Keys(tagIDs))
+	for i, t := range ix.names {
+		tagIDs[t] = uint32(i)
+	}
+
+	ix.tags = make([]Bitmap, len(ix.names))
+	ix.fileTags = make([][]uint32, len(ix.entries))
+	for i, e := range ix.entries {
+		id := uint32(i)
+		ix.all.Add(id)
+		for _, t := range e.tags {
+			tid := tagIDs[t]
+			// NOTE: aliases are already merged, so a tag repeats only if a file lists it twice:
+			if !slices.Contains(ix.fileTags[i], tid) {
+				ix.fileTags[i] = append(ix.fileTags[i], tid)
+				ix.tags[tid].Add(id)
+			}
+		}
+	}
+	return ix
+}
+
+// the number of files.
+func (ix *Index) Len() int {
+	return len(ix.entries)
+}
+
+// the entries of a Bitmap of file ids, in order.
+func (ix *Index) Entries(b Bitmap) []Entry {
+	entries := make([]Entry, 0, b.Len())
+	for id := range b.All() {
+		entries = append(entries, ix.entries[id])
+	}
+	return entries
+}
+
+// the filenames of a Bitmap of file ids.
+func (ix *Index) Set(b Bitmap) Set {
+	s := make(Set, b.Len())
+	for id := range b.All() {
+		s.Add(ix.entries[id].filename)
+	}
+	return s
+}
+
+// the file ids of a Set of filenames. those not in the index are left out.
+func (ix *Index) Bitmap(s Set) Bitmap {
+	b := Bitmap{}
+	for id, e := range ix.entries {
+		if s[e.filename] {
+			b.Add(uint32(id))
+		}
+	}
+	return b
+}
+
+// the same as MakeTagmap of the entries.
+func (ix *Index) Tagmap() map[string]Set {
+	tagmap := make(map[string]Set, len(ix.names))
+	for tid, t := range ix.names {
+		tagmap[t] = ix.Set(ix.tags[tid])
+	}
+	return tagmap
+}
+
+// the files matched by a single query term, ignoring NOT. an invalid pattern matches nothing.
+func (ix *Index) lookup(term string) Bitmap {
+	b := Bitmap{}
+	match, err := matcher(term)
+	if err != nil {
+		return b
+	}
+	for tid, t := range ix.names {
+		if match(t) {
+			b = b.Or(ix.tags[tid])
+		}
+	}
+	return b
+}
+
+// the files matched by a single query term, which may be negated.
+func (ix *Index) evalTerm(term string) Bitmap {
+	t, not := cutNot(term)
+	b := ix.lookup(t)
+	if not {
+		return ix.all.AndNot(b)
+	}
+	return b
+}
+
+// the tags a query term matched, sorted. for the verbose summary, so that a pattern or parent shows
+// what it stood for.
+func (ix *Index) expand(term string) []string {
+	expanded := []string{}
+	match, err := matcher(term)
+	if err != nil {
+		return expanded
+	}
+	for _, t := range ix.names {
+		if match(t) {
+			expanded = append(expanded, t)
+		}
+	}
+	return expanded
+}
+
+// the files matching a query, as um tag lists them. exported for commands taking the same query.
+func (ix *Index) Match(query string, inv bool) (Bitmap, error) {
+	q := parseQuery(query)
+	if err := q.validate(); err != nil {
+		return Bitmap{}, err
+	}
+	files := processQueries(ix, q)
+	if inv {
+		files = ix.all.AndNot(files)
+	}
+	return files, nil
+}
@@ -3,13 +3,16 @@ package tag
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/brtholomy/um/go/cat"
 	"github.com/brtholomy/um/go/pipe"
 )
 
@@ -24,11 +27,16 @@ const (
 
 	// ^+ tag$
 	TAG_REGEXP = `(?m)^\+ (.+)$`
+
+	// an um filename alone on its line, as in the file link lists um cat can strip. like
+	// next.FILE_REGEXP, but never spanning whitespace, so that a numbered list item isn't a link:
+	LINK_REGEXP = `(?m)^[0-9]+\.[^\.\s]*\.*md$`
 )
 
 // regexp.Compile is by far the most expensive operation of ParseContent:
 var dateRegexp *regexp.Regexp = regexp.MustCompile(DATE_REGEXP)
 var tagRegexp *regexp.Regexp = regexp.MustCompile(TAG_REGEXP)
+var linkRegexp *regexp.Regexp = regexp.MustCompile(LINK_REGEXP)
 
 type Entry struct {
 	filename string
@@ -74,47 +82,127 @@ func parseContent(filename string, content *string) Entry {
 	}
 }
 
-// reads files from stdin if present, otherwise from the glob pattern:
-func getFilelist(glob string) []string {
-	filelist, err := pipe.GetStdin()
-	// otherwise get from the glob:
+// reads and parses a single um file. exported for commands which only need the header.
+func ParseFile(f string) (Entry, error) {
+	dat, err := os.ReadFile(f)
 	if err != nil {
-		filelist, err = filepath.Glob(glob)
-		if err != nil {
-			log.Fatal(err)
+		return Entry{}, fmt.Errorf("error opening file: %s\n%w", f, err)
+	}
+	s := string(dat)
+	return parseContent(f, &s), nil
+}
+
+// the base name of the file.
+func (e Entry) Filename() string {
+	return e.filename
+}
+
+func (e Entry) Date() time.Time {
+	return e.date
+}
+
+func (e Entry) Tags() []string {
+	return e.tags
+}
+
+// the H1 title, or the filename if the header has none.
+func (e Entry) Title() string {
+	header := parseHeader(&e.content)
+	line, _, _ := strings.Cut(header, pipe.Newline)
+	if title, ok := strings.CutPrefix(line, cat.H1); ok {
+		return title
+	}
+	return e.filename
+}
+
+// the um files linked from the content below the header, in order of appearance, without repeats.
+func (e Entry) Links() []string {
+	links := []string{}
+	for _, l := range linkRegexp.FindAllString(e.Body(), -1) {
+		if !slices.Contains(links, l) {
+			links = append(links, l)
 		}
 	}
-	return filelist
+	return links
 }
 
-// create []Entry representing qualifying files in current directory or from stdin
-func entriesGlobOrStdin(glob string) []Entry {
-	filelist := getFilelist(glob)
+// the content below the header.
+func (e Entry) Body() string {
+	body := e.content
+	if strings.HasPrefix(body, cat.H1) {
+		// a header-only file has no body:
+		_, body, _ = strings.Cut(body, cat.DOUBLE_NEWLINE)
+	}
+	return body
+}
 
+// word count of the content below the header.
+func (e Entry) Words() int {
+	return len(strings.Fields(e.Body()))
+}
+
+// reads files from stdin if present, otherwise from the glob pattern:
+func getFilelist(stdin io.Reader, glob string, sep string) ([]string, error) {
+	filelist, err := pipe.GetStdin(stdin, sep)
+	// otherwise get from the glob:
+	if errors.Is(err, pipe.ErrNoStdin) {
+		return filepath.Glob(glob)
+	}
+	return filelist, err
+}
+
+// create []Entry representing qualifying files in current directory or from stdin. exported for the
+// commands which report on the collection.
+func EntriesGlobOrStdin(stdin io.Reader, glob string, sep string) ([]Entry, error) {
+	filelist, err := getFilelist(stdin, glob, sep)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFiles(filelist)
+}
+
+// tags are renamed by the aliases here, so that everything after sees only canonical names. exported
+// for um watch, which parses only the files that changed.
+func ParseFiles(filelist []string) ([]Entry, error) {
+	aliases, err := LoadAliases()
+	if err != nil {
+		return nil, err
+	}
 	// NOTE: size 0, capacity specified:
 	entries := make([]Entry, 0, len(filelist))
 	for _, f := range filelist {
-		dat, err := os.ReadFile(f)
+		e, err := ParseFile(f)
 		if err != nil {
-			log.Fatal(fmt.Errorf("gag: error opening file: %s\n%w", f, err))
+			return nil, err
 		}
-		s := string(dat)
-		e := parseContent(f, &s)
+		e.tags = aliases.apply(e.tags)
 		entries = append(entries, e)
 	}
-	return entries
+	return entries, nil
+}
+
+// the sorted tag names of the files matching glob, including the parents of hierarchical tags. never
+// reads stdin, since it's meant for um completion.
+func Names(glob string) ([]string, error) {
+	filelist, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ParseFiles(filelist)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(Rollup(MakeTagmap(entries)))), nil
 }
 
-// maps tags to a set of filenames
-func makeTagmap(entries []Entry) map[string]Set {
+// maps tags to a set of filenames. exported for um tags.
+func MakeTagmap(entries []Entry) map[string]Set {
 	tagmap := map[string]Set{}
 	for _, e := range entries {
 		for _, tag := range e.tags {
-			// allocate submap if necessary:
-			if _, ok := tagmap[tag]; !ok {
-				tagmap[tag] = Set{}
-			}
-			tagmap[tag].Add(e.filename)
+			files := tagmap[tag]
+			files.Add(e.filename)
+			tagmap[tag] = files
 		}
 	}
 	return tagmap
@@ -6,6 +6,7 @@ import (
 	"io"
 	"maps"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -16,30 +17,41 @@ type TagCount struct {
 	count int
 }
 
-// just-in-time sort of our tag list for the sake of printFiles
-func orderedTags(tagmap map[string]Set, query Query) []TagCount {
-	ordered_tags := []TagCount{}
+// just-in-time sort of our tag list for the sake of printFiles. the query terms are counted in the
+// index, which is only needed for them.
+func orderedTags(tagmap map[string]Set, query Query, ix *Index) []TagCount {
 	// TODO: there's code smell about this whole approach.
 	if query.Op == WILD {
-		for q, s := range tagmap {
-			ordered_tags = append(ordered_tags, TagCount{q, len(s)})
-		}
-	} else {
-		for _, q := range query.Tags {
-			ordered_tags = append(ordered_tags, TagCount{q, len(tagmap[q])})
-		}
+		// parents count all the files of their descendants:
+		return countedTags(Rollup(tagmap))
+	}
+	ordered_tags := []TagCount{}
+	for _, q := range query.Tags {
+		ordered_tags = append(ordered_tags, TagCount{q, ix.evalTerm(q).Len()})
+	}
+	return sortTags(ordered_tags)
+}
+
+// the tags of tagmap as they are, without a rollup. reduceAdjacencies has already rolled up the
+// adjacencies, and rolling them up again would bring back the ancestors it left out.
+func countedTags(tagmap map[string]Set) []TagCount {
+	counted := make([]TagCount, 0, len(tagmap))
+	for t, s := range tagmap {
+		counted = append(counted, TagCount{t, len(s)})
 	}
-	slices.SortFunc(ordered_tags, func(i, j TagCount) int {
-		return cmp.Compare(i.count, j.count)
+	return sortTags(counted)
+}
+
+func sortTags(tags []TagCount) []TagCount {
+	slices.SortFunc(tags, func(i, j TagCount) int {
+		return cmp.Or(cmp.Compare(i.count, j.count), strings.Compare(i.name, j.name))
 	})
-	return ordered_tags
+	return tags
 }
 
-// prints out the intersected tagmap
-func sprintFiles(files Set) string {
-	ordered_files := make([]string, len(files))
-	copy(ordered_files, slices.Collect(maps.Keys(files)))
-	slices.Sort(ordered_files)
+// prints out the intersected tagmap, in the order of the key if there is one
+func sprintFiles(files Set, key []string) string {
+	ordered_files := orderBy(slices.Sorted(maps.Keys(files)), key)
 	// NOTE: I assume this is as efficient as strings.Builder :
 	return fmt.Sprintln(strings.Join(ordered_files, "\n"))
 }
@@ -48,17 +60,18 @@ func sprintFiles(files Set) string {
 // and original query tags.
 //
 // format is a TOML syntax possibly useful elsewhere.
-func printFiles(w io.Writer, entries []Entry, tagmap map[string]Set, files Set, adjacencies map[string]Set, query Query, verbose bool) {
-	f := sprintFiles(files)
+func printFiles(w io.Writer, ix *Index, files Bitmap, key []string, adjacencies map[string]Set, query Query, verbose bool) {
+	f := sprintFiles(ix.Set(files), key)
 	if !verbose {
-		fmt.Print(f)
+		fmt.Fprint(w, f)
 		return
 	}
+	tagmap := ix.Tagmap()
 	filesstr := fmt.Sprintln("[files]")
 	filesstr += f
 
 	tags := fmt.Sprintln("[tags]")
-	otags := orderedTags(tagmap, query)
+	otags := orderedTags(tagmap, query, ix)
 	tsb := strings.Builder{}
 	// 20 * ' ' + '= 000\n' = 26
 	tsb.Grow(len(otags) * 26)
@@ -67,20 +80,41 @@ func printFiles(w io.Writer, entries []Entry, tagmap map[string]Set, files Set,
 	}
 	tags += tsb.String()
 
+	// only terms which stood for more than themselves, as a pattern or a parent does:
+	matched := ""
+	if query.Op != WILD {
+		for _, q := range query.Tags {
+			t, _ := cutNot(q)
+			if e := ix.expand(q); !slices.Equal(e, []string{t}) {
+				quoted := make([]string, len(e))
+				for i, t := range e {
+					quoted[i] = strconv.Quote(t)
+				}
+				matched += fmt.Sprintf("%-20s= [%s]\n", q, strings.Join(quoted, ", "))
+			}
+		}
+	}
+	if matched != "" {
+		tags += fmt.Sprintln()
+		tags += fmt.Sprintln("[matched]")
+		tags += matched
+	}
+
+	rolled := Rollup(tagmap)
 	adj := fmt.Sprintln("[adjacencies]")
-	oadj := orderedTags(adjacencies, Query{WILD, []string{}})
+	oadj := countedTags(adjacencies)
 	asb := strings.Builder{}
 	// 20 * ' ' + '= 000 : 000\n' = 32
 	asb.Grow(len(oadj) * 32)
 	for _, t := range oadj {
-		// TODO: something's fucky about these len() with --invert :
-		asb.WriteString(fmt.Sprintf("%-20s= %-3d : %d\n", t.name, t.count, len(tagmap[t.name])))
+		// matched files with the tag : all files with it
+		asb.WriteString(fmt.Sprintf("%-20s= %-3d : %d\n", t.name, t.count, len(rolled[t.name])))
 	}
 	adj += asb.String()
 
 	sums := fmt.Sprintln("[sums]")
-	sums += fmt.Sprintf("files               = %-3d : %d\n", len(files), len(entries))
-	sums += fmt.Sprintf("adjacencies         = %-3d : %d\n", len(adjacencies), len(tagmap))
+	sums += fmt.Sprintf("files               = %-3d : %d\n", files.Len(), ix.Len())
+	sums += fmt.Sprintf("adjacencies         = %-3d : %d\n", len(adjacencies), len(rolled))
 
 	fmt.Fprintln(w, filesstr)
 	fmt.Fprintln(w, tags)
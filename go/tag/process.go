@@ -1,143 +1,152 @@
 package tag
 
 import (
-	"maps"
+	"fmt"
 	"slices"
 	"strings"
 	"time"
+
+	"github.com/brtholomy/um/go/cmd"
 )
 
-// shrinks the entries to only include files within a date range.
-func dateRange(entries []Entry, date string) []Entry {
+// shrinks the entries to only include files within a date range. exported for --date in other commands.
+func DateRange(entries []Entry, date string) ([]Entry, error) {
 	// deleting from the old slice would be less efficient than appending to a new one:
 	ranged := make([]Entry, 0, len(entries))
-	from, to := time.Time{}, time.Time{}
 
 	// when there's no range, the first string here will be the input:
 	f, t, ok := strings.Cut(date, "-")
-	from, _ = time.Parse(DATE_FORMAT, f)
-	if ok {
-		to, _ = time.Parse(DATE_FORMAT, t)
-	} else {
+	if !ok {
 		// use the from date for the case of a single date given:
-		to, _ = time.Parse(DATE_FORMAT, f)
+		t = f
+	}
+	from, err := time.Parse(DATE_FORMAT, f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid date: %s", cmd.ErrUsage, f)
+	}
+	to, err := time.Parse(DATE_FORMAT, t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid date: %s", cmd.ErrUsage, t)
 	}
 	for _, e := range entries {
 		if from.Compare(e.date) <= 0 && 0 <= to.Compare(e.date) {
 			ranged = append(ranged, e)
 		}
 	}
-	return ranged
+	return ranged, nil
 }
 
-// produce a Set reduced to the files covered by combined queries
-func processQueries(tagmap map[string]Set, query Query) Set {
-	set := Set{}
+// produce a Bitmap reduced to the files covered by combined queries. negated terms are complements
+// over every file, so that files without any tag can be matched too.
+func processQueries(ix *Index, query Query) Bitmap {
 	// sanity check:
 	if len(query.Tags) == 0 {
-		return set
-	}
-
-	// initialize as first query
-	q := query.Tags[0]
-	// NOTE: clone so that we don't accidentally overwrite the incoming tagmap
-	set = maps.Clone(tagmap[q])
-	// if first query matches nothing: set will be nil and Union will fail:
-	// TODO: solve this in set.Union by moving to pointer receiver.
-	if set == nil {
-		set = Set{}
+		return Bitmap{}
 	}
-
-	// single tag: no need for set logic whether the empty query or not
-	// TODO: do I want to handle WILD and a tag? Actual regex?
-	if len(query.Tags) == 1 {
-		if query.Op == WILD && q == "" {
-			// NOTE: this is all files with at least one tag and therefore of value:
-			set.Union(slices.Collect(maps.Values(tagmap))...)
+	// TODO: do I want to handle WILD and a tag?
+	if query.Op == WILD && query.Tags[0] == "" {
+		// NOTE: this is all files with at least one tag and therefore of value:
+		files := Bitmap{}
+		for _, b := range ix.tags {
+			files = files.Or(b)
 		}
-		// non-empty query should return also:
-		return set
+		return files
 	}
 
-	for _, t := range query.Tags {
+	files := ix.evalTerm(query.Tags[0])
+	for _, t := range query.Tags[1:] {
 		switch query.Op {
 		case OR:
-			set.Union(tagmap[t])
+			files = files.Or(ix.evalTerm(t))
 		case AND:
-			set.Intersect(tagmap[t])
+			files = files.And(ix.evalTerm(t))
 		}
 	}
-	return set
+	return files
 }
 
-// inverts the filelist using the full list from entries. works with intersected queries as long as
-// processQueries is called first.
-func invert(entries []Entry, files Set) Set {
-	set := Set{}
-	for _, e := range entries {
-		if _, ok := files[e.filename]; !ok {
-			set.Add(e.filename)
+// adjacencies is a map from tag to a map of other tags occuring in the given files.
+func MakeAdjacencies(ix *Index, files Bitmap) map[string]map[string]Bitmap {
+	// by tag id, so that no tag name is hashed per file:
+	byID := make([]map[uint32]*Bitmap, len(ix.names))
+	for id := range files.All() {
+		tids := ix.fileTags[id]
+		for _, a := range tids {
+			for _, b := range tids {
+				if a == b {
+					continue
+				}
+				// allocate submap if necessary:
+				if byID[a] == nil {
+					byID[a] = map[uint32]*Bitmap{}
+				}
+				if byID[a][b] == nil {
+					byID[a][b] = &Bitmap{}
+				}
+				byID[a][b].Add(id)
+			}
 		}
 	}
-	return set
-}
-
-// adjacencies is a map from tag to a map of other tags occuring in the given files.
-func makeAdjacencies(entries []Entry, files Set) map[string]map[string]Set {
-	adjacencies := map[string]map[string]Set{}
-
-	for _, e := range entries {
-		// NOTE: this allows for a filelist shrunk after entries slice was made:
-		if !files[e.filename] {
+	adjacencies := map[string]map[string]Bitmap{}
+	for a, others := range byID {
+		if others == nil {
 			continue
 		}
-		for i, tag := range e.tags {
-			// make a slice copy but minus the current tag:
-			others := make([]string, len(e.tags))
-			copy(others, e.tags)
-			others = slices.Delete(others, i, i+1)
-
-			// allocate submap if necessary:
-			if _, ok := adjacencies[tag]; !ok {
-				adjacencies[tag] = map[string]Set{}
-			}
-			for _, other := range others {
-				if _, ok := adjacencies[tag][other]; !ok {
-					adjacencies[tag][other] = Set{}
-				}
-				adjacencies[tag][other].Add(e.filename)
-			}
+		adjacencies[ix.names[a]] = make(map[string]Bitmap, len(others))
+		for b, shared := range others {
+			adjacencies[ix.names[a]][ix.names[b]] = *shared
 		}
 	}
 	return adjacencies
 }
 
-// reduces adjacencies to a single map[tag]Set not including the query tags
-func reduceAdjacencies(adjacencies map[string]map[string]Set, query Query, invert bool) map[string]Set {
-	reduced := map[string]Set{}
-	if invert {
-		// TODO: something's wrong here...
-		// adjacencies keys will already reflect all tags built from an inverted filelist
-		for _, adjmap := range adjacencies {
-			for adj, files := range adjmap {
-				if _, ok := reduced[adj]; !ok {
-					reduced[adj] = Set{}
-				}
-				reduced[adj].Union(files)
+// the tags on the matched files which the query doesn't already account for, each with the matched
+// files having it. a positive query term accounts for the tags it matches and their ancestors, and
+// the empty query for every tag. an inverted query accounts for none, since its files are the ones
+// outside it.
+func reduceAdjacencies(ix *Index, files Bitmap, query Query, invert bool) map[string]Set {
+	matchers := []func(string) bool{}
+	ancestors := Set{}
+	if !invert && query.Op == WILD {
+		// the empty query is about every tag:
+		return map[string]Set{}
+	}
+	if !invert {
+		for _, q := range query.Tags {
+			if _, not := cutNot(q); not {
+				continue
+			}
+			if match, err := matcher(q); err == nil {
+				matchers = append(matchers, match)
+			}
+			if !isPattern(q) {
+				ancestors.Add(Ancestors(strings.TrimPrefix(q, string(EXACT)))...)
 			}
 		}
-
-		return reduced
 	}
-	for _, qtag := range query.Tags {
-		for adjtag, files := range adjacencies[qtag] {
-			if !slices.Contains(query.Tags, adjtag) {
-				if _, ok := reduced[adjtag]; !ok {
-					reduced[adjtag] = Set{}
-				}
-				reduced[adjtag].Add(files.Members()...)
+	queried := func(t string) bool {
+		return ancestors[t] || slices.ContainsFunc(matchers, func(match func(string) bool) bool { return match(t) })
+	}
+
+	reduced := map[string]Bitmap{}
+	for tid, t := range ix.names {
+		if queried(t) {
+			continue
+		}
+		adj := ix.tags[tid].And(files)
+		if adj.Len() == 0 {
+			continue
+		}
+		// parents of adjacent tags are adjacent too:
+		for _, a := range append([]string{t}, Ancestors(t)...) {
+			if !queried(a) {
+				reduced[a] = reduced[a].Or(adj)
 			}
 		}
 	}
-	return reduced
+	sets := make(map[string]Set, len(reduced))
+	for t, b := range reduced {
+		sets[t] = ix.Set(b)
+	}
+	return sets
 }
@@ -1,6 +1,14 @@
 package tag
 
-import "strings"
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+)
 
 type Operator string
 
@@ -9,6 +17,17 @@ const (
 	OR     Operator = ","
 	AND    Operator = "+"
 	WILD   Operator = "*"
+	// prefixes a single term to match the tag alone, and not its descendants:
+	EXACT Operator = "="
+	// prefixes a single term to match the files without it:
+	NOT Operator = "!"
+)
+
+const (
+	// a term between slashes is a regex:
+	REGEX_DELIM = "/"
+	// a term with any of these is a glob, as path.Match understands them:
+	GLOB_CHARS = "*?["
 )
 
 type Query struct {
@@ -32,7 +51,7 @@ func parseQuery(query string) Query {
 	// NOTE: will match OR first
 	ops := []Operator{OR, AND}
 	for _, op := range ops {
-		if s := strings.Split(query, string(op)); len(s) > 1 {
+		if s := splitTerms(query, op); len(s) > 1 {
 			q.Op = op
 			q.Tags = s
 			break
@@ -41,3 +60,82 @@ func parseQuery(query string) Query {
 	}
 	return q
 }
+
+// splits the query on op, but never inside a regex term, which may well contain a + of its own.
+func splitTerms(query string, op Operator) []string {
+	terms := []string{}
+	start := 0
+	regex := false
+	for i := 0; i < len(query); i++ {
+		switch {
+		case regex && query[i] == '\\':
+			// skip the escaped char, which may be the delimiter:
+			i++
+		case regex && strings.HasPrefix(query[i:], REGEX_DELIM):
+			regex = false
+		case i == start && strings.HasPrefix(query[i:], REGEX_DELIM):
+			regex = true
+		case !regex && strings.HasPrefix(query[i:], string(op)):
+			terms = append(terms, query[start:i])
+			start = i + len(op)
+		}
+	}
+	return append(terms, query[start:])
+}
+
+// the term without NOT, and whether it had it.
+func cutNot(term string) (string, bool) {
+	return strings.CutPrefix(term, string(NOT))
+}
+
+func isRegex(term string) bool {
+	return len(term) > 1 && strings.HasPrefix(term, REGEX_DELIM) && strings.HasSuffix(term, REGEX_DELIM)
+}
+
+func isPattern(term string) bool {
+	return isRegex(term) || strings.ContainsAny(term, GLOB_CHARS)
+}
+
+// what a query term matches: the tag itself or any of its descendants, unless the term is EXACT. a
+// pattern matches a tag if it matches the tag or any of its ancestors, so that a matched parent
+// covers its descendants just the same. NOT is ignored here, since it complements the files rather
+// than the tags.
+func matcher(term string) (func(string) bool, error) {
+	term, _ = cutNot(term)
+	if exact, ok := strings.CutPrefix(term, string(EXACT)); ok {
+		return func(t string) bool { return t == exact }, nil
+	}
+	var match func(string) bool
+	switch {
+	case isRegex(term):
+		re, err := regexp.Compile(term[len(REGEX_DELIM) : len(term)-len(REGEX_DELIM)])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid regex: %s: %s", cmd.ErrUsage, term, err)
+		}
+		match = re.MatchString
+	case isPattern(term):
+		if _, err := path.Match(term, ""); err != nil {
+			return nil, fmt.Errorf("%w: invalid glob: %s: %s", cmd.ErrUsage, term, err)
+		}
+		match = func(t string) bool {
+			ok, _ := path.Match(term, t)
+			return ok
+		}
+	default:
+		match = func(t string) bool { return t == term }
+	}
+	return func(t string) bool {
+		return match(t) || slices.ContainsFunc(Ancestors(t), match)
+	}, nil
+}
+
+// checks that every pattern in the query compiles, since matching treats one that doesn't as
+// matching nothing.
+func (q Query) validate() error {
+	for _, t := range q.Tags {
+		if _, err := matcher(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package tag
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+	"github.com/brtholomy/um/go/pipe"
+)
+
+const (
+	// um tag @name runs the query saved as name:
+	SAVED_PREFIX = "@"
+	// a query saved in the config has a section of its own, as [query.essays]:
+	SAVED_SECTION = "query."
+	// a filelist may declare the query generating it in a header, and is then saved under its own
+	// name:
+	LIST_EXT = ".um"
+)
+
+// the keys of a saved query, in a config section or a filelist header.
+const (
+	QUERY_KEY  = "query"
+	DATE_KEY   = "date"
+	INVERT_KEY = "invert"
+	SORT_KEY   = "key"
+)
+
+// a query saved under a name, with the flags it runs with.
+type Saved struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Date   string `json:"date,omitempty"`
+	Invert bool   `json:"invert"`
+	// a filelist whose order the matching files follow, as for um sort --key. a filelist declaring its
+	// own query is its own key:
+	Key string `json:"key,omitempty"`
+}
+
+// finds a saved query by name: a section of the config, or else the header of name.um in the current
+// directory.
+func LoadSaved(name string) (Saved, error) {
+	conf, err := config.Load()
+	if err != nil {
+		return Saved{}, err
+	}
+	if section := conf.Section(SAVED_SECTION + name); section != nil {
+		return parseSaved(name, config.Path(), section)
+	}
+	s, ok, err := ReadHeader(name + LIST_EXT)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && !ok {
+		return Saved{}, fmt.Errorf("%w: no saved query: %s%s", cmd.ErrNotFound, SAVED_PREFIX, name)
+	}
+	return s, err
+}
+
+// a query as um tag takes it: a saved one by @name, where date given here wins over the saved one and
+// invert inverts it again, or else a query of its own. exported for um serve.
+func Resolve(query, date string, invert bool) (Saved, error) {
+	name, ok := strings.CutPrefix(query, SAVED_PREFIX)
+	if !ok {
+		return Saved{Query: query, Date: date, Invert: invert}, nil
+	}
+	s, err := LoadSaved(name)
+	if err != nil {
+		return s, err
+	}
+	s.Date = cmp.Or(date, s.Date)
+	s.Invert = invert != s.Invert
+	return s, nil
+}
+
+// the query a filelist declares in its header: the comments it starts with, each as key = value like
+// the config. other comments, as um sort --comment leaves, are skipped. false if it declares none.
+//
+//	# query = essays+!draft
+//	# date = 2024.01.01-2024.12.31
+//	01.essay.md
+func ReadHeader(f string) (Saved, bool, error) {
+	lines, err := pipe.FileListSplit(f)
+	if err != nil {
+		return Saved{}, false, err
+	}
+	section := map[string]string{}
+	for _, l := range lines {
+		kv, ok := strings.CutPrefix(l, pipe.Comment)
+		if !ok {
+			break
+		}
+		conf, err := config.Parse(f, kv)
+		if err != nil {
+			continue
+		}
+		for k, v := range conf.Section("") {
+			if slices.Contains([]string{QUERY_KEY, DATE_KEY, INVERT_KEY}, k) {
+				section[k] = v
+			}
+		}
+	}
+	if _, ok := section[QUERY_KEY]; !ok {
+		return Saved{}, false, nil
+	}
+	section[SORT_KEY] = f
+	s, err := parseSaved(strings.TrimSuffix(filepath.Base(f), LIST_EXT), f, section)
+	return s, err == nil, err
+}
+
+// a saved query from its keys. unknown keys are ignored, as in the config. file is only used for
+// error messages.
+func parseSaved(name, file string, section map[string]string) (Saved, error) {
+	s := Saved{Name: name, Query: section[QUERY_KEY], Date: section[DATE_KEY], Key: section[SORT_KEY]}
+	if _, ok := section[QUERY_KEY]; !ok {
+		return s, fmt.Errorf("%w: %s: saved query %s has no %s", cmd.ErrParse, file, name, QUERY_KEY)
+	}
+	if v, ok := section[INVERT_KEY]; ok {
+		invert, err := strconv.ParseBool(v)
+		if err != nil {
+			return s, fmt.Errorf("%w: %s: invalid %s: %s", cmd.ErrParse, file, INVERT_KEY, v)
+		}
+		s.Invert = invert
+	}
+	return s, parseQuery(s.Query).validate()
+}
+
+// the filenames in the key, in order. a key not written yet is empty.
+func (s Saved) readKey() ([]string, error) {
+	if s.Key == "" {
+		return nil, nil
+	}
+	key, err := pipe.FileListSplit(s.Key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return key, err
+}
+
+// the files matching a saved query, in the order of its key. exported for um watch.
+func (s Saved) Filelist(entries []Entry) ([]string, error) {
+	var err error
+	if s.Date != "" {
+		if entries, err = DateRange(entries, s.Date); err != nil {
+			return nil, err
+		}
+	}
+	ix := NewIndex(entries)
+	files, err := ix.Match(s.Query, s.Invert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.readKey()
+	if err != nil {
+		return nil, err
+	}
+	return orderBy(slices.Sorted(maps.Keys(ix.Set(files))), key), nil
+}
+
+// the filenames in the order of the key: those in it first as it has them, then the rest as they
+// were. a comment in the key never matches a filename, so it orders nothing.
+func orderBy(filenames []string, key []string) []string {
+	if len(key) == 0 {
+		return filenames
+	}
+	pos := make(map[string]int, len(key))
+	for i, l := range key {
+		if _, ok := pos[l]; !ok {
+			pos[l] = i
+		}
+	}
+	rank := func(f string) int {
+		if i, ok := pos[f]; ok {
+			return i
+		}
+		return len(key)
+	}
+	ordered := slices.Clone(filenames)
+	slices.SortStableFunc(ordered, func(a, b string) int { return rank(a) - rank(b) })
+	return ordered
+}
+
+// the names of the saved queries, sorted: those in the config, and the filelists in the current
+// directory declaring their own. exported for um completion.
+func SavedNames() ([]string, error) {
+	conf, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for section := range conf {
+		if name, ok := strings.CutPrefix(section, SAVED_SECTION); ok {
+			names = append(names, name)
+		}
+	}
+	lists, err := filepath.Glob("*" + LIST_EXT)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lists {
+		if _, ok, err := ReadHeader(l); err == nil && ok {
+			names = append(names, strings.TrimSuffix(l, LIST_EXT))
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
@@ -6,14 +6,18 @@ import (
 )
 
 // convenience shorthand for this awkward map type.
+//
+// the mutating methods take a pointer, so that they work on the nil Set as well as the empty one.
+// a Set in a map isn't addressable, so take it out, change it, and put it back.
 type Set map[string]bool
 
 // add members to the "set"
-// TODO: this probably should have been a pointer receiver to handle the nil map case.
-// currently doing some roundabout checks before calling.
-func (s Set) Add(mems ...string) {
+func (s *Set) Add(mems ...string) {
+	if *s == nil {
+		*s = Set{}
+	}
 	for _, m := range mems {
-		s[m] = true
+		(*s)[m] = true
 	}
 }
 
@@ -22,18 +26,42 @@ func (s Set) Members() []string {
 	return slices.Collect(maps.Keys(s))
 }
 
+// a copy which is never nil.
+func (s Set) Clone() Set {
+	c := Set{}
+	maps.Copy(c, s)
+	return c
+}
+
 // s ∪ t
-func (s Set) Union(tt ...Set) {
+func (s *Set) Union(tt ...Set) {
+	s.Add()
 	for _, t := range tt {
-		s.Add(t.Members()...)
+		maps.Copy(*s, t)
 	}
 }
 
 // s ∩ t
-func (s Set) Intersect(t Set) {
-	for m, _ := range s {
+func (s *Set) Intersect(t Set) {
+	s.Add()
+	for m := range *s {
 		if !t[m] {
-			delete(s, m)
+			delete(*s, m)
 		}
 	}
 }
+
+// s − t
+func (s *Set) Difference(t Set) {
+	s.Add()
+	for m := range t {
+		delete(*s, m)
+	}
+}
+
+// universe − s, as a new set. members of s outside the universe are simply not in it.
+func (s Set) Complement(universe Set) Set {
+	c := universe.Clone()
+	c.Difference(s)
+	return c
+}
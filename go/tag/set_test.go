@@ -0,0 +1,438 @@
+package tag
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a small vocabulary, so that random files share tags often. science has descendants, and the
+// collection may have files with no tags at all.
+var vocabulary = []string{"art", "foo", "bar", "science", "science/physics", "science/physics/optics"}
+
+func randomEntries(r *rand.Rand) []Entry {
+	entries := make([]Entry, r.Intn(30))
+	for i := range entries {
+		entries[i].filename = fmt.Sprintf("%02d.md", i)
+		for _, t := range vocabulary {
+			if r.Intn(3) == 0 {
+				entries[i].tags = append(entries[i].tags, t)
+			}
+		}
+	}
+	return entries
+}
+
+func randomSet(r *rand.Rand, universe Set) Set {
+	s := Set{}
+	for m := range universe {
+		if r.Intn(2) == 0 {
+			s.Add(m)
+		}
+	}
+	return s
+}
+
+// random ids over a few containers, each empty, sparse, or dense enough to be a bitset, with the Set
+// of the same ids.
+func randomBitmap(r *rand.Rand) (Bitmap, Set) {
+	b, s := Bitmap{}, Set{}
+	for key := range 3 {
+		for range []int{0, ARRAY_MAX / 40, 2 * ARRAY_MAX}[r.Intn(3)] {
+			id := uint32(key<<CONTAINER_BITS | r.Intn(1<<CONTAINER_BITS))
+			b.Add(id)
+			s.Add(fmt.Sprint(id))
+		}
+	}
+	return b, s
+}
+
+func toSet(b Bitmap) Set {
+	s := Set{}
+	for id := range b.All() {
+		s.Add(fmt.Sprint(id))
+	}
+	return s
+}
+
+func sets(ix *Index, m map[string]Bitmap) map[string]Set {
+	s := map[string]Set{}
+	for k, b := range m {
+		s[k] = ix.Set(b)
+	}
+	return s
+}
+
+// checks a law against n random collections, each from its own seed so that a failure can be
+// reproduced.
+func checkLaw(t *testing.T, n int, law func(r *rand.Rand) bool) {
+	t.Helper()
+	err := quick.Check(func(seed int64) bool {
+		return law(rand.New(rand.NewSource(seed)))
+	}, &quick.Config{MaxCount: n})
+	assert.NoError(t, err)
+}
+
+func union(ss ...Set) Set {
+	u := Set{}
+	u.Union(ss...)
+	return u
+}
+
+func intersect(a, b Set) Set {
+	i := a.Clone()
+	i.Intersect(b)
+	return i
+}
+
+// a query over Sets of filenames, as it was evaluated before the Index. the oracle for
+// processQueries, and its baseline in the benchmarks.
+func setQuery(tagmap map[string]Set, universe Set, query Query) Set {
+	if query.Op == WILD {
+		return union(slices.Collect(maps.Values(tagmap))...)
+	}
+	term := func(q string) Set {
+		t, not := cutNot(q)
+		s := Set{}
+		if match, err := matcher(t); err == nil {
+			for tag, files := range tagmap {
+				if match(tag) {
+					s.Union(files)
+				}
+			}
+		}
+		if not {
+			return s.Complement(universe)
+		}
+		return s
+	}
+	s := term(query.Tags[0])
+	for _, q := range query.Tags[1:] {
+		if query.Op == OR {
+			s.Union(term(q))
+		} else {
+			s.Intersect(term(q))
+		}
+	}
+	return s
+}
+
+// the adjacencies over Sets of filenames, as they were made before the Index.
+func setAdjacencies(entries []Entry, files Set) map[string]map[string]Set {
+	adjacencies := map[string]map[string]Set{}
+	for _, e := range entries {
+		if !files[e.filename] {
+			continue
+		}
+		for _, t := range e.tags {
+			for _, other := range e.tags {
+				if other == t {
+					continue
+				}
+				if adjacencies[t] == nil {
+					adjacencies[t] = map[string]Set{}
+				}
+				s := adjacencies[t][other]
+				s.Add(e.filename)
+				adjacencies[t][other] = s
+			}
+		}
+	}
+	return adjacencies
+}
+
+func TestNilSet(t *testing.T) {
+	var s Set
+	s.Union(nil)
+	assert.Equal(t, Set{}, s)
+
+	s = nil
+	s.Intersect(Set{"a": true})
+	assert.Equal(t, Set{}, s)
+
+	s = nil
+	s.Add("a")
+	assert.Equal(t, Set{"a": true}, s)
+	assert.Equal(t, Set{"b": true}, s.Complement(Set{"a": true, "b": true}))
+	assert.Equal(t, Set{}, Set(nil).Clone())
+}
+
+func TestSetLaws(t *testing.T) {
+	universeOf := func(r *rand.Rand) Set {
+		ix := NewIndex(randomEntries(r))
+		return ix.Set(ix.all)
+	}
+
+	t.Run("de morgan", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			u := universeOf(r)
+			a, b := randomSet(r, u), randomSet(r, u)
+			return maps.Equal(union(a, b).Complement(u), intersect(a.Complement(u), b.Complement(u))) &&
+				maps.Equal(intersect(a, b).Complement(u), union(a.Complement(u), b.Complement(u)))
+		})
+	})
+	t.Run("idempotence", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			u := universeOf(r)
+			a := randomSet(r, u)
+			return maps.Equal(union(a, a), a) && maps.Equal(intersect(a, a), a)
+		})
+	})
+	t.Run("double complement", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			u := universeOf(r)
+			a := randomSet(r, u)
+			return maps.Equal(a.Complement(u).Complement(u), a)
+		})
+	})
+}
+
+func TestBitmap(t *testing.T) {
+	b := Bitmap{}
+	assert.Equal(t, 0, b.Len())
+	b.Add(3, 1, 1<<CONTAINER_BITS+2, 3)
+	assert.Equal(t, 3, b.Len())
+	assert.Equal(t, []uint32{1, 3, 1<<CONTAINER_BITS + 2}, slices.Collect(b.All()))
+	assert.True(t, b.Has(1<<CONTAINER_BITS+2))
+	assert.False(t, b.Has(2))
+	assert.False(t, b.Has(2<<CONTAINER_BITS))
+
+	// past ARRAY_MAX a container becomes a bitset, and an operation leaving it sparse makes it an
+	// array again:
+	dense := Bitmap{}
+	for id := range uint32(ARRAY_MAX + 1) {
+		dense.Add(id)
+	}
+	assert.NotNil(t, dense.containers[0].bitset)
+	assert.Equal(t, ARRAY_MAX+1, dense.Len())
+	sparse := dense.And(b)
+	assert.Nil(t, sparse.containers[0].bitset)
+	assert.Equal(t, []uint32{1, 3}, slices.Collect(sparse.All()))
+
+	// an emptied container is dropped:
+	assert.Empty(t, dense.AndNot(dense).keys)
+	assert.Empty(t, b.And(Bitmap{}).keys)
+}
+
+// Bitmaps must agree with Sets, whatever mix of containers they hold.
+func TestBitmapLaws(t *testing.T) {
+	checkLaw(t, 20, func(r *rand.Rand) bool {
+		a, as := randomBitmap(r)
+		b, bs := randomBitmap(r)
+		diff := as.Clone()
+		diff.Difference(bs)
+		u := a.Or(b)
+		return maps.Equal(toSet(a.Or(b)), union(as, bs)) &&
+			maps.Equal(toSet(a.And(b)), intersect(as, bs)) &&
+			maps.Equal(toSet(a.AndNot(b)), diff) &&
+			a.Or(b).Len() == len(union(as, bs)) &&
+			a.And(b).Len() == len(intersect(as, bs)) &&
+			// de morgan within a ∪ b:
+			maps.Equal(toSet(u.AndNot(a.And(b))), toSet(u.AndNot(a).Or(u.AndNot(b)))) &&
+			u.AndNot(a.Or(b)).Len() == 0 &&
+			// idempotence:
+			maps.Equal(toSet(a.Or(a)), as) && maps.Equal(toSet(a.And(a)), as)
+	})
+}
+
+func TestQueryLaws(t *testing.T) {
+	// a term of the vocabulary: plain, exact or a pattern:
+	term := func(r *rand.Rand) string {
+		t := vocabulary[r.Intn(len(vocabulary))]
+		switch r.Intn(4) {
+		case 0:
+			return string(EXACT) + t
+		case 1:
+			return t[:1] + "*"
+		}
+		return t
+	}
+	eval := func(ix *Index, query string) Set {
+		return ix.Set(processQueries(ix, parseQuery(query)))
+	}
+
+	t.Run("sets", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			entries := randomEntries(r)
+			ix := NewIndex(entries)
+			ops := []string{",", "+"}
+			query := parseQuery(term(r) + ops[r.Intn(2)] + string(NOT) + term(r))
+			return maps.Equal(ix.Set(processQueries(ix, query)), setQuery(MakeTagmap(entries), ix.Set(ix.all), query))
+		})
+	})
+	t.Run("de morgan", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			ix := NewIndex(randomEntries(r))
+			u := ix.Set(ix.all)
+			a, b := term(r), term(r)
+			or := fmt.Sprintf("%s,%s", a, b)
+			and := fmt.Sprintf("%s+%s", a, b)
+			return maps.Equal(eval(ix, or).Complement(u), eval(ix, "!"+a+"+!"+b)) &&
+				maps.Equal(eval(ix, and).Complement(u), eval(ix, "!"+a+",!"+b))
+		})
+	})
+	t.Run("idempotence", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			ix := NewIndex(randomEntries(r))
+			a := term(r)
+			return maps.Equal(eval(ix, a+","+a), eval(ix, a)) &&
+				maps.Equal(eval(ix, a+"+"+a), eval(ix, a))
+		})
+	})
+	t.Run("excluded middle", func(t *testing.T) {
+		checkLaw(t, 200, func(r *rand.Rand) bool {
+			ix := NewIndex(randomEntries(r))
+			a := term(r)
+			return maps.Equal(eval(ix, a+",!"+a), ix.Set(ix.all)) &&
+				len(eval(ix, a+"+!"+a)) == 0
+		})
+	})
+}
+
+// whatever the query, an adjacent tag counts exactly the matched files having it or a descendant of
+// it. an inverted query accounts for no tags, so every tag of its files is adjacent.
+func TestAdjacencyLaws(t *testing.T) {
+	shapes := []string{"", "foo", "science", "=science", "foo,bar", "foo+bar", "!foo", "foo+!science", "s*"}
+	checkLaw(t, 200, func(r *rand.Rand) bool {
+		entries := randomEntries(r)
+		ix := NewIndex(entries)
+		query := parseQuery(shapes[r.Intn(len(shapes))])
+		invert := r.Intn(2) == 0
+		files := processQueries(ix, query)
+		if invert {
+			files = ix.all.AndNot(files)
+		}
+		fs := ix.Set(files)
+		// the bitmaps agree with the sets:
+		bitmaps := map[string]map[string]Set{}
+		for t, m := range MakeAdjacencies(ix, files) {
+			if len(m) > 0 {
+				bitmaps[t] = sets(ix, m)
+			}
+		}
+		if !maps.EqualFunc(bitmaps, setAdjacencies(entries, fs), func(a, b map[string]Set) bool {
+			return maps.EqualFunc(a, b, maps.Equal)
+		}) {
+			return false
+		}
+
+		adjacencies := reduceAdjacencies(ix, files, query, invert)
+		rolled := Rollup(MakeTagmap(entries))
+		for tag, adj := range adjacencies {
+			if !maps.Equal(adj, intersect(fs, rolled[tag])) {
+				return false
+			}
+		}
+		if !invert {
+			return true
+		}
+		return slices.Equal(slices.Sorted(maps.Keys(adjacencies)), slices.Sorted(maps.Keys(Rollup(MakeTagmap(ix.Entries(files))))))
+	})
+}
+
+// a collection the size of one kept for decades: tag use falls off as it does in real ones, and a
+// fifth of the tags are nested under another.
+func syntheticEntries(n int) []Entry {
+	r := rand.New(rand.NewSource(1))
+	names := make([]string, 2000)
+	for i := range names {
+		names[i] = fmt.Sprintf("tag%d", i)
+		if i > 0 && i%5 == 0 {
+			names[i] = names[r.Intn(i)] + SEP + names[i]
+		}
+	}
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(len(names)-1))
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i].filename = fmt.Sprintf("%05d.md", i)
+		for range r.Intn(6) + 1 {
+			entries[i].tags = append(entries[i].tags, names[zipf.Uint64()])
+		}
+		slices.Sort(entries[i].tags)
+		entries[i].tags = slices.Compact(entries[i].tags)
+	}
+	return entries
+}
+
+// run with -bench 'Tagmap|Index|Query|Adjacencies' to compare the Index with the Sets it replaced:
+// on testdata against BenchmarkTagmap and BenchmarkAdjacencies, and on a collection of 50k. a query
+// has one operator, so each is benchmarked on its own.
+var BENCH_QUERIES = []string{"tag1+!tag3", "tag1,tag2"}
+
+func BenchmarkIndex(b *testing.B) {
+	entries := testEntries(b)
+	for b.Loop() {
+		NewIndex(entries)
+	}
+}
+
+func BenchmarkSetAdjacencies(b *testing.B) {
+	entries := testEntries(b)
+	ix := NewIndex(entries)
+	files := ix.Set(processQueries(ix, parseQuery("foo")))
+	for b.Loop() {
+		setAdjacencies(entries, files)
+	}
+}
+
+func BenchmarkTagmap50k(b *testing.B) {
+	entries := syntheticEntries(50_000)
+	for b.Loop() {
+		MakeTagmap(entries)
+	}
+}
+
+func BenchmarkIndex50k(b *testing.B) {
+	entries := syntheticEntries(50_000)
+	for b.Loop() {
+		NewIndex(entries)
+	}
+}
+
+func BenchmarkSetQuery50k(b *testing.B) {
+	entries := syntheticEntries(50_000)
+	ix := NewIndex(entries)
+	tagmap, u := MakeTagmap(entries), ix.Set(ix.all)
+	for _, q := range BENCH_QUERIES {
+		query := parseQuery(q)
+		b.Run(q, func(b *testing.B) {
+			for b.Loop() {
+				setQuery(tagmap, u, query)
+			}
+		})
+	}
+}
+
+func BenchmarkQuery50k(b *testing.B) {
+	ix := NewIndex(syntheticEntries(50_000))
+	for _, q := range BENCH_QUERIES {
+		query := parseQuery(q)
+		b.Run(q, func(b *testing.B) {
+			for b.Loop() {
+				processQueries(ix, query)
+			}
+		})
+	}
+}
+
+func BenchmarkSetAdjacencies50k(b *testing.B) {
+	entries := syntheticEntries(50_000)
+	ix := NewIndex(entries)
+	files := ix.Set(processQueries(ix, parseQuery("tag1")))
+	for b.Loop() {
+		setAdjacencies(entries, files)
+	}
+}
+
+func BenchmarkAdjacencies50k(b *testing.B) {
+	ix := NewIndex(syntheticEntries(50_000))
+	files := processQueries(ix, parseQuery("tag1"))
+	for b.Loop() {
+		MakeAdjacencies(ix, files)
+	}
+}
@@ -1,14 +1,13 @@
 package tag
 
 import (
-	"errors"
-	"fmt"
-	"log"
-	"os"
+	"context"
+	"io"
 
 	"github.com/brtholomy/um/go/cmd"
 	"github.com/brtholomy/um/go/flags"
 	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
 )
 
 const (
@@ -17,50 +16,91 @@ const (
 )
 
 type options struct {
-	Query   flags.Arg
-	Date    flags.String
-	Invert  flags.Bool
-	Verbose flags.Bool
-	Help    flags.Bool
+	Query        flags.Arg
+	Date         flags.String
+	Invert       flags.Bool
+	Verbose      flags.Bool
+	Suggest      flags.Bool
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
 }
 
 func initOpts() options {
 	return options{
-		flags.Arg{"", "tag query: understands intersection '+' and union ','"},
-		flags.String{"--date", "-d", "", "date range in ISO 8601: YYYY.MM.DD[-YYYY.MM.DD]"},
-		flags.Bool{"--invert", "-i", false, "invert match"},
-		flags.Bool{"--verbose", "-v", false, "print a verbose summary"},
-		flags.Bool{"--help", "-h", false, "show help"},
+		flags.Arg{"", "tag query: understands intersection '+' and union ','. terms may be globs or /regexes/, and negated by '!'. @name runs a saved query", "tags"},
+		flags.String{"--date", "-d", "", "date range in ISO 8601: YYYY.MM.DD[-YYYY.MM.DD]", "none", nil},
+		flags.Bool{"--invert", "-i", false, "invert match", nil},
+		flags.Bool{"--verbose", "-v", false, "print a verbose summary", nil},
+		flags.Bool{"--suggest-aliases", "-s", false, "list near-duplicate tags as aliases for " + ALIAS_FILE + ", instead of files", nil},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
 	}
 }
 
-func Tag(args []string) {
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	opts := initOpts()
 	help := flags.NewHelpError(CMD, SUMMARY)
 	if err := flags.ParseArgs(help, args, &opts); err != nil {
-		if errors.As(err, &help) {
-			fmt.Println(help)
-			return
-		}
-		log.Fatalf("um %s: %s", CMD, err)
+		return err
+	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
+
+	// a saved query brings its own flags:
+	saved, err := Resolve(opts.Query.Val, opts.Date.Val, opts.Invert.Val)
+	if err != nil {
+		return err
+	}
+	opts.Query.Val, opts.Date.Val, opts.Invert.Val = saved.Query, saved.Date, saved.Invert
+	key, err := saved.readKey()
+	if err != nil {
+		return err
 	}
 
 	queries := parseQuery(opts.Query.Val)
-	entries := entriesGlobOrStdin(last.GLOB)
+	if err := queries.validate(); err != nil {
+		return err
+	}
+	entries, err := EntriesGlobOrStdin(stdin, last.GLOB, pipe.Sep(opts.Null.Val))
+	if err != nil {
+		return err
+	}
 
 	// we shrink the entries list immediately if we want a date range:
 	if opts.Date.IsSet() {
-		entries = dateRange(entries, opts.Date.Val)
+		entries, err = DateRange(entries, opts.Date.Val)
+		if err != nil {
+			return err
+		}
+	}
+	ix := NewIndex(entries)
+	if opts.Suggest.Val {
+		printAliases(stdout, ix.Tagmap())
+		return nil
 	}
-	tagmap := makeTagmap(entries)
 
-	// processQueries must precede invert because we want invert to respect combined tags:
-	files := processQueries(tagmap, queries)
-	if opts.Invert.IsSet() {
-		files = invert(entries, files)
+	// invert is the complement of the whole query, over every file rather than only the tagged ones:
+	files := processQueries(ix, queries)
+	if opts.Invert.Val {
+		files = ix.all.AndNot(files)
+	}
+	// only the verbose summary has them:
+	var adjacencies map[string]Set
+	if opts.Verbose.Val {
+		adjacencies = reduceAdjacencies(ix, files, queries, opts.Invert.Val)
 	}
-	// NOTE: the full makeAdjacencies map may one day be useful on its own
-	adjacencies := reduceAdjacencies(makeAdjacencies(entries, files), queries, opts.Invert.Val)
 
-	printFiles(os.Stdout, entries, tagmap, files, adjacencies, queries, opts.Verbose.Val)
+	printFiles(stdout, ix, files, key, adjacencies, queries, opts.Verbose.Val)
+	return nil
 }
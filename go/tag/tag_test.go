@@ -2,24 +2,37 @@ package tag
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+	"github.com/brtholomy/um/go/pipe"
 	// TODO: switch to something lighter: https://github.com/alecthomas/assert
 	"github.com/stretchr/testify/assert"
 )
 
 const TEST_PATTERN string = "./testdata/*.md"
 
+func testEntries(tb testing.TB) []Entry {
+	entries, err := EntriesGlobOrStdin(nil, TEST_PATTERN, pipe.Newline)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return entries
+}
+
 func TestParseHeader(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
+	entries := testEntries(t)
 	header := parseHeader(&entries[0].content)
 	expected := "# 01.foo.md\n: 2024.09.25\n+ bar\n+ foo"
 	assert.Equal(t, expected, header)
 }
 
 func TestEntriesLen(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
+	entries := testEntries(t)
 	expected := 6
 	if len(entries) != expected {
 		t.Errorf("entries should be len == %v, got %v", expected, len(entries))
@@ -27,40 +40,40 @@ func TestEntriesLen(t *testing.T) {
 }
 
 func TestEntries(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
+	entries := testEntries(t)
 	d, _ := time.Parse("2006.01.02", "2024.09.25")
 	expected := Entry{filename: "01.foo.md", date: d, content: "# 01.foo.md\n: 2024.09.25\n+ bar\n+ foo\n\nFoo bar.\n", tags: []string{"bar", "foo"}}
 	assert.Equal(t, expected, entries[0])
 }
 
 func TestTagmap(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(t)
+	tagmap := MakeTagmap(entries)
 	expected := Set{"01.foo.md": true, "02.foo.md": true, "03.bar.md": true}
 	assert.Equal(t, expected, tagmap["bar"])
 }
 
 func TestAdjacencies(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(t)
+	ix := NewIndex(entries)
 	queries := parseQuery("bar")
-	fs := processQueries(tagmap, queries)
-	adjacencies := makeAdjacencies(entries, fs)
+	fs := processQueries(ix, queries)
+	adjacencies := MakeAdjacencies(ix, fs)
 	expected := map[string]Set{
 		"foo":     Set{"01.foo.md": true},
 		"science": Set{"02.foo.md": true, "03.bar.md": true},
 	}
-	assert.Equal(t, expected, adjacencies["bar"])
+	assert.Equal(t, expected, sets(ix, adjacencies["bar"]))
 }
 
 func TestPrint(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(t)
+	ix := NewIndex(entries)
 	query := parseQuery("bar")
-	fs := processQueries(tagmap, query)
-	adjacencies := reduceAdjacencies(makeAdjacencies(entries, fs), query, false)
+	fs := processQueries(ix, query)
+	adjacencies := reduceAdjacencies(ix, fs, query, false)
 	buf := bytes.Buffer{}
-	printFiles(&buf, entries, tagmap, fs, adjacencies, query, true)
+	printFiles(&buf, ix, fs, nil, adjacencies, query, true)
 	expected := `[files]
 01.foo.md
 02.foo.md
@@ -82,61 +95,61 @@ adjacencies         = 2   : 4
 }
 
 func TestBadTag(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(t)
+	tagmap := MakeTagmap(entries)
 
 	_, ok := tagmap["qaz"]
 	assert.False(t, ok)
 }
 
 func TestBadTagOr(t *testing.T) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(t)
+	ix := NewIndex(entries)
 	queries := parseQuery("flob,bar")
-	fs := processQueries(tagmap, queries)
+	fs := processQueries(ix, queries)
 	expected := Set{"01.foo.md": true, "02.foo.md": true, "03.bar.md": true}
-	assert.Equal(t, expected, fs)
+	assert.Equal(t, expected, ix.Set(fs))
 }
 
-// Since entriesGlobOrStdin() involves filesystem reads, we test the underlying logic.
+// Since EntriesGlobOrStdin() involves filesystem reads, we test the underlying logic.
 func BenchmarkParseContent(b *testing.B) {
-	e := entriesGlobOrStdin(TEST_PATTERN)[0]
+	e := testEntries(b)[0]
 	for b.Loop() {
 		parseContent(e.filename, &e.content)
 	}
 }
 
 func BenchmarkTagmap(b *testing.B) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
+	entries := testEntries(b)
 	for b.Loop() {
-		makeTagmap(entries)
+		MakeTagmap(entries)
 	}
 }
 
 func BenchmarkAdjacencies(b *testing.B) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(b)
+	ix := NewIndex(entries)
 	queries := parseQuery("foo")
-	fs := processQueries(tagmap, queries)
+	fs := processQueries(ix, queries)
 	for b.Loop() {
-		makeAdjacencies(entries, fs)
+		MakeAdjacencies(ix, fs)
 	}
 }
 
 func BenchmarkPrint(b *testing.B) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
-	tagmap := makeTagmap(entries)
+	entries := testEntries(b)
+	ix := NewIndex(entries)
 	query := parseQuery("bar")
-	fs := processQueries(tagmap, query)
-	adjacencies := reduceAdjacencies(makeAdjacencies(entries, fs), query, false)
+	fs := processQueries(ix, query)
+	adjacencies := reduceAdjacencies(ix, fs, query, false)
 	buf := bytes.Buffer{}
 	for b.Loop() {
-		printFiles(&buf, entries, tagmap, fs, adjacencies, query, true)
+		printFiles(&buf, ix, fs, nil, adjacencies, query, true)
 	}
 }
 
 func FuzzParseContent(f *testing.F) {
-	entries := entriesGlobOrStdin(TEST_PATTERN)
+	entries := testEntries(f)
 	for _, e := range entries {
 		f.Add(e.filename, e.content)
 	}
@@ -147,3 +160,256 @@ func FuzzParseContent(f *testing.F) {
 		}
 	})
 }
+
+func TestEntryHeader(t *testing.T) {
+	entries := testEntries(t)
+	assert.Equal(t, "01.foo.md", entries[0].Title())
+	assert.Equal(t, 2, entries[0].Words())
+	assert.Equal(t, []string{"bar", "foo"}, entries[0].Tags())
+
+	content := "no header here\n"
+	e := parseContent("untitled.md", &content)
+	assert.Equal(t, "untitled.md", e.Title())
+	assert.Equal(t, 3, e.Words())
+}
+
+// a small hierarchy built in memory, so that the testdata stays flat:
+func treeEntries() []Entry {
+	return []Entry{
+		{filename: "01.a.md", tags: []string{"science"}},
+		{filename: "02.b.md", tags: []string{"science/physics", "art"}},
+		{filename: "03.c.md", tags: []string{"science/physics/optics"}},
+		{filename: "04.d.md", tags: []string{"science/biology", "art"}},
+		{filename: "05.e.md", tags: []string{"sciencefiction"}},
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	assert.Equal(t, []string{"science/physics", "science"}, Ancestors("science/physics/optics"))
+	assert.Equal(t, []string{}, Ancestors("science"))
+}
+
+func TestHierarchyQuery(t *testing.T) {
+	ix := NewIndex(treeEntries())
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		// a parent matches all its descendants, but not tags it merely prefixes:
+		{"science", []string{"01.a.md", "02.b.md", "03.c.md", "04.d.md"}},
+		{"science/physics", []string{"02.b.md", "03.c.md"}},
+		{"=science", []string{"01.a.md"}},
+		{"=science/physics", []string{"02.b.md"}},
+		{"science+art", []string{"02.b.md", "04.d.md"}},
+		{"=science,science/biology", []string{"01.a.md", "04.d.md"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			assert.ElementsMatch(t, tc.want, ix.Set(processQueries(ix, parseQuery(tc.query))).Members())
+		})
+	}
+}
+
+func TestRollup(t *testing.T) {
+	rolled := Rollup(MakeTagmap(treeEntries()))
+	assert.Len(t, rolled["science"], 4)
+	assert.Len(t, rolled["science/physics"], 2)
+	assert.Len(t, rolled["science/physics/optics"], 1)
+	assert.Len(t, rolled["sciencefiction"], 1)
+}
+
+func TestHierarchyAdjacencies(t *testing.T) {
+	entries := treeEntries()
+	query := parseQuery("science/physics")
+	ix := NewIndex(entries)
+	fs := processQueries(ix, query)
+	adjacencies := reduceAdjacencies(ix, fs, query, false)
+	// neither descendants nor ancestors of the query are adjacent to it:
+	assert.Equal(t, map[string]Set{"art": {"02.b.md": true}}, adjacencies)
+}
+
+func TestCanonical(t *testing.T) {
+	a := Aliases{names: map[string]string{"phil": "philosophy", "sci": "science", "science": "sci"}}
+	assert.Equal(t, "philosophy", a.Canonical("phil"))
+	// a parent's alias renames its descendants, but not tags it merely prefixes:
+	assert.Equal(t, "philosophy/ethics", a.Canonical("phil/ethics"))
+	assert.Equal(t, "philately", a.Canonical("philately"))
+	// case is kept unless folded:
+	assert.Equal(t, "Phil", a.Canonical("Phil"))
+	a.fold = true
+	assert.Equal(t, "philosophy", a.Canonical("Phil"))
+	// a loop stops where it started:
+	assert.Equal(t, "sci", a.Canonical("sci"))
+
+	assert.Equal(t, []string{"philosophy", "art"}, a.apply([]string{"phil", "art", "Philosophy"}))
+}
+
+func TestLoadAliases(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	conf := filepath.Join(dir, "config.toml")
+	t.Setenv(config.ENV, conf)
+	assert.NoError(t, os.WriteFile(conf, []byte("[aliases]\nphil = philosophy\nsci = science\n"), 0664))
+	assert.NoError(t, os.WriteFile(ALIAS_FILE, []byte("fold-case = true\n\n[aliases]\nSci = natural science\n"), 0664))
+
+	a, err := LoadAliases()
+	assert.NoError(t, err)
+	// the alias file wins over the config, and folding applies to both:
+	assert.Equal(t, Aliases{map[string]string{"phil": "philosophy", "sci": "natural science"}, true}, a)
+
+	assert.NoError(t, os.WriteFile(ALIAS_FILE, []byte("fold-case = maybe\n"), 0664))
+	_, err = LoadAliases()
+	assert.ErrorIs(t, err, cmd.ErrParse)
+}
+
+func TestSuggestAliases(t *testing.T) {
+	tagmap := map[string]Set{
+		"philosophy": {"01.md": true, "02.md": true},
+		"Philosophy": {"03.md": true},
+		"philosphy":  {"04.md": true},
+		"art":        {"05.md": true},
+		"arc":        {"06.md": true},
+	}
+	expected := []suggestion{
+		{"Philosophy", "philosophy", 0},
+		{"philosphy", "philosophy", 1},
+	}
+	assert.Equal(t, expected, suggestAliases(tagmap))
+
+	buf := bytes.Buffer{}
+	printAliases(&buf, tagmap)
+	assert.Contains(t, buf.String(), "[aliases]\nPhilosophy          = \"philosophy\"          # case: 1 : 2\n")
+}
+
+func TestParseQueryRegex(t *testing.T) {
+	// a + or , inside a regex doesn't split it:
+	assert.Equal(t, Query{AND, []string{`/^proj-\d+$/`, "art"}}, parseQuery(`/^proj-\d+$/+art`))
+	assert.Equal(t, Query{OR, []string{`/a,b/`, "c"}}, parseQuery(`/a,b/,c`))
+	assert.Equal(t, Query{OR, []string{`/a\/,b/`, "c"}}, parseQuery(`/a\/,b/,c`))
+	// nor does a slash within a hierarchical tag start one:
+	assert.Equal(t, Query{AND, []string{"science/physics", "art"}}, parseQuery("science/physics+art"))
+}
+
+func TestPatternQuery(t *testing.T) {
+	ix := NewIndex(treeEntries())
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"scien*", []string{"01.a.md", "02.b.md", "03.c.md", "04.d.md", "05.e.md"}},
+		// a glob matching a parent covers its descendants:
+		{"science/ph*", []string{"02.b.md", "03.c.md"}},
+		{"/^science$/", []string{"01.a.md", "02.b.md", "03.c.md", "04.d.md"}},
+		{"/fiction$/,art", []string{"02.b.md", "04.d.md", "05.e.md"}},
+		{"/bio/+art", []string{"04.d.md"}},
+		{"qux*", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			q := parseQuery(tc.query)
+			assert.NoError(t, q.validate())
+			assert.ElementsMatch(t, tc.want, ix.Set(processQueries(ix, q)).Members())
+		})
+	}
+	assert.ErrorIs(t, parseQuery("/(/").validate(), cmd.ErrUsage)
+	assert.ErrorIs(t, parseQuery("[a").validate(), cmd.ErrUsage)
+}
+
+func TestPrintMatched(t *testing.T) {
+	entries := treeEntries()
+	ix := NewIndex(entries)
+	query := parseQuery("science/ph*")
+	fs := processQueries(ix, query)
+	adjacencies := reduceAdjacencies(ix, fs, query, false)
+	buf := bytes.Buffer{}
+	printFiles(&buf, ix, fs, nil, adjacencies, query, true)
+	assert.Contains(t, buf.String(), "[matched]\nscience/ph*         = [\"science/physics\", \"science/physics/optics\"]\n")
+	assert.Contains(t, buf.String(), "[adjacencies]\nart                 = 1   : 2\n")
+}
+
+func TestPrintHierarchy(t *testing.T) {
+	entries := append(treeEntries(), Entry{filename: "06.f.md", tags: []string{"science/physics/optics", "science/biology"}})
+	ix := NewIndex(entries)
+	query := parseQuery("science/physics")
+	fs := processQueries(ix, query)
+	adjacencies := reduceAdjacencies(ix, fs, query, false)
+	buf := bytes.Buffer{}
+	printFiles(&buf, ix, fs, nil, adjacencies, query, true)
+	// science is an ancestor of the query, so it is no adjacency even though science/biology is:
+	expected := `[files]
+02.b.md
+03.c.md
+06.f.md
+
+[tags]
+science/physics     = 3
+
+[matched]
+science/physics     = ["science/physics", "science/physics/optics"]
+
+[adjacencies]
+art                 = 1   : 2
+science/biology     = 1   : 2
+
+[sums]
+files               = 3   : 6
+adjacencies         = 2   : 6
+
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestSaved(t *testing.T) {
+	entries := testEntries(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	conf := filepath.Join(dir, "config.toml")
+	t.Setenv(config.ENV, conf)
+	assert.NoError(t, os.WriteFile(conf, []byte("[query.recent]\nquery = science\ndate = 2024.10.01-2024.12.31\nkey = recent.um\n\n[query.bad]\ninvert = maybe\nquery = foo\n"), 0664))
+	assert.NoError(t, os.WriteFile("bars.um", []byte("# query = bar\n# 01.foo.md\n# invert = true\n03.bar.md\n"), 0664))
+
+	s, err := LoadSaved("recent")
+	assert.NoError(t, err)
+	assert.Equal(t, Saved{"recent", "science", "2024.10.01-2024.12.31", false, "recent.um"}, s)
+	// a key not written yet orders nothing:
+	files, err := s.Filelist(entries)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"04.baz.md"}, files)
+
+	// a header skips comments which aren't keys, and the filelist is its own key:
+	s, err = LoadSaved("bars")
+	assert.NoError(t, err)
+	assert.Equal(t, Saved{"bars", "bar", "", true, "bars.um"}, s)
+	files, err = s.Filelist(entries)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"04.baz.md", "05.quz.md", "06.quz.md"}, files)
+	assert.NoError(t, os.WriteFile("bars.um", []byte("# query = bar\n05.quz.md\n06.quz.md\n"), 0664))
+	s, _ = LoadSaved("bars")
+	files, _ = s.Filelist(entries)
+	assert.Equal(t, []string{"01.foo.md", "02.foo.md", "03.bar.md"}, files)
+	assert.NoError(t, os.WriteFile("bars.um", []byte("# query = bar\n# invert = true\n05.quz.md\n06.quz.md\n"), 0664))
+	s, _ = LoadSaved("bars")
+	files, _ = s.Filelist(entries)
+	assert.Equal(t, []string{"05.quz.md", "06.quz.md", "04.baz.md"}, files)
+
+	_, ok, err := ReadHeader("bars.um")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile("plain.um", []byte("# 01.foo.md\n01.foo.md\n# query = foo\n"), 0664))
+	_, ok, err = ReadHeader("plain.um")
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	_, err = LoadSaved("bad")
+	assert.ErrorIs(t, err, cmd.ErrParse)
+	_, err = LoadSaved("plain")
+	assert.ErrorIs(t, err, cmd.ErrNotFound)
+	_, err = LoadSaved("missing")
+	assert.ErrorIs(t, err, cmd.ErrNotFound)
+}
+
+func TestOrderBy(t *testing.T) {
+	files := []string{"01.md", "02.md", "03.md", "04.md"}
+	assert.Equal(t, files, orderBy(files, nil))
+	assert.Equal(t, []string{"03.md", "01.md", "02.md", "04.md"}, orderBy(files, []string{"# 02.md", "03.md", "05.md", "01.md", "03.md"}))
+}
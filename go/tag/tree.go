@@ -0,0 +1,32 @@
+package tag
+
+import (
+	"strings"
+)
+
+// separates the levels of a hierarchical tag: science/physics is a kind of science.
+const SEP = "/"
+
+// the ancestors of a tag, nearest first: science/physics/optics -> science/physics, science
+func Ancestors(t string) []string {
+	ancestors := []string{}
+	for i := strings.LastIndex(t, SEP); i > 0; i = strings.LastIndex(t, SEP) {
+		t = t[:i]
+		ancestors = append(ancestors, t)
+	}
+	return ancestors
+}
+
+// the tagmap with each tag's files counted under all of its ancestors too, which appear even when no
+// file has them directly.
+func Rollup(tagmap map[string]Set) map[string]Set {
+	rolled := make(map[string]Set, len(tagmap))
+	for t, files := range tagmap {
+		for _, a := range append([]string{t}, Ancestors(t)...) {
+			r := rolled[a]
+			r.Union(files)
+			rolled[a] = r
+		}
+	}
+	return rolled
+}
@@ -0,0 +1,37 @@
+package tags
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brtholomy/um/go/tag"
+)
+
+// in place of the dates of a tag on undated files alone:
+const UNDATED = "-"
+
+// the tags aligned in columns, as um stats prints its periods. a tree shows each tag by its last
+// level, indented under its parent.
+func printText(w io.Writer, tt []Tag, tree bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "tag\tfiles\tfirst\tlast")
+	for _, t := range tt {
+		name := t.Name
+		if tree {
+			name = strings.Repeat("  ", strings.Count(name, tag.SEP)) + name[strings.LastIndex(name, tag.SEP)+1:]
+		}
+		first, last := cmp.Or(t.First, UNDATED), cmp.Or(t.Last, UNDATED)
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", name, t.Files, first, last)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, tt []Tag) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tt)
+}
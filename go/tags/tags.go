@@ -0,0 +1,192 @@
+package tags
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Tags
+	SUMMARY = "list the tags in use, with the number of files having each and when they were used"
+)
+
+type Sort string
+
+const (
+	NAME  Sort = "name"
+	COUNT Sort = "count"
+	// most recently used first:
+	RECENCY Sort = "recency"
+)
+
+type Format string
+
+const (
+	TEXT Format = "text"
+	JSON Format = "json"
+)
+
+type options struct {
+	Pattern      flags.Arg
+	Sort         flags.String
+	Tree         flags.Bool
+	Date         flags.String
+	Format       flags.String
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Arg{"", "only tags starting with this prefix, or matching a /regex/", "tags"},
+		flags.String{"--sort", "-s", string(NAME), "name | count | recency", "name|count|recency", &flags.Default{}},
+		flags.Bool{"--tree", "-t", false, "show the tag hierarchy, each parent counting the files of its descendants", &flags.Default{}},
+		flags.String{"--date", "-d", "", "date range in ISO 8601: YYYY.MM.DD[-YYYY.MM.DD]", "none", nil},
+		flags.String{"--format", "-f", string(TEXT), "text | json", "text|json", &flags.Default{}},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	// dates of the first and last files having the tag. empty if none of them is dated:
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+// compares tags level by level, so that children follow their parent directly.
+func compareTree(a, b string) int {
+	return slices.Compare(strings.Split(a, tag.SEP), strings.Split(b, tag.SEP))
+}
+
+// the filter for a pattern argument: a regex between slashes, as in a tag query, or else a prefix.
+// the empty pattern matches everything.
+func matcher(pattern string) (func(string) bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, tag.REGEX_DELIM) && strings.HasSuffix(pattern, tag.REGEX_DELIM) {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	return func(t string) bool {
+		return strings.HasPrefix(t, pattern)
+	}, nil
+}
+
+// the tags of the tagmap which match, with the span of dates of their files.
+func tags(entries []tag.Entry, tagmap map[string]tag.Set, match func(string) bool) []Tag {
+	dates := make(map[string]time.Time, len(entries))
+	for _, e := range entries {
+		dates[e.Filename()] = e.Date()
+	}
+	tt := []Tag{}
+	for name, files := range tagmap {
+		if !match(name) {
+			continue
+		}
+		var first, last time.Time
+		for f := range files {
+			d := dates[f]
+			if d.IsZero() {
+				continue
+			}
+			if first.IsZero() || d.Before(first) {
+				first = d
+			}
+			if d.After(last) {
+				last = d
+			}
+		}
+		t := Tag{Name: name, Files: len(files)}
+		if !first.IsZero() {
+			t.First, t.Last = first.Format(tag.DATE_FORMAT), last.Format(tag.DATE_FORMAT)
+		}
+		tt = append(tt, t)
+	}
+	return tt
+}
+
+// ties are always in name order. a tree keeps children under their parent, whatever the order.
+func sortTags(tt []Tag, by Sort, tree bool) {
+	slices.SortFunc(tt, func(a, b Tag) int {
+		if tree {
+			return compareTree(a.Name, b.Name)
+		}
+		switch by {
+		case COUNT:
+			return cmp.Or(cmp.Compare(b.Files, a.Files), strings.Compare(a.Name, b.Name))
+		case RECENCY:
+			// NOTE: the date format sorts as a string, and undated tags come last:
+			return cmp.Or(strings.Compare(b.Last, a.Last), strings.Compare(a.Name, b.Name))
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
+	// BORK: by hand for now:
+	if !slices.Contains([]Sort{NAME, COUNT, RECENCY}, Sort(opts.Sort.Val)) {
+		return help.HelpInvalidValue(opts.Sort.Long, opts.Sort.Val)
+	}
+	if !slices.Contains([]Format{TEXT, JSON}, Format(opts.Format.Val)) {
+		return help.HelpInvalidValue(opts.Format.Long, opts.Format.Val)
+	}
+	match, err := matcher(opts.Pattern.Val)
+	if err != nil {
+		return help.HelpInvalidArg(opts.Pattern.Val)
+	}
+
+	entries, err := tag.EntriesGlobOrStdin(stdin, last.GLOB, pipe.Sep(opts.Null.Val))
+	if err != nil {
+		return err
+	}
+	if opts.Date.IsSet() {
+		entries, err = tag.DateRange(entries, opts.Date.Val)
+		if err != nil {
+			return err
+		}
+	}
+	tagmap := tag.MakeTagmap(entries)
+	if opts.Tree.Val {
+		tagmap = tag.Rollup(tagmap)
+	}
+	tt := tags(entries, tagmap, match)
+	sortTags(tt, Sort(opts.Sort.Val), opts.Tree.Val)
+
+	if Format(opts.Format.Val) == JSON {
+		return printJSON(stdout, tt)
+	}
+	return printText(stdout, tt, opts.Tree.Val)
+}
@@ -0,0 +1,93 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/brtholomy/um/go/tag"
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_PATTERN string = "./testdata/*.md"
+
+func testEntries(t *testing.T) []tag.Entry {
+	entries, err := tag.EntriesGlobOrStdin(nil, TEST_PATTERN, "\n")
+	assert.NoError(t, err)
+	return entries
+}
+
+func names(tt []Tag) []string {
+	nn := []string{}
+	for _, t := range tt {
+		nn = append(nn, t.Name)
+	}
+	return nn
+}
+
+func TestTags(t *testing.T) {
+	entries := testEntries(t)
+	all, _ := matcher("")
+	tt := tags(entries, tag.MakeTagmap(entries), all)
+
+	sortTags(tt, NAME, false)
+	assert.Equal(t, Tag{"art", 2, "2024.02.03", "2024.03.02"}, tt[0])
+	assert.Equal(t, Tag{"undated", 1, "", ""}, tt[len(tt)-1])
+
+	sortTags(tt, COUNT, false)
+	assert.Equal(t, "art", tt[0].Name)
+
+	sortTags(tt, RECENCY, false)
+	assert.Equal(t, []string{"art", "science/physics", "science/physics/optics", "science-fiction", "science/biology", "science", "undated"}, names(tt))
+}
+
+func TestMatcher(t *testing.T) {
+	entries := testEntries(t)
+	tagmap := tag.MakeTagmap(entries)
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{"science/", []string{"science/biology", "science/physics", "science/physics/optics"}},
+		{"/^s.*s$/", []string{"science/physics", "science/physics/optics"}},
+		{"/", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			match, err := matcher(tc.pattern)
+			assert.NoError(t, err)
+			tt := tags(entries, tagmap, match)
+			sortTags(tt, NAME, false)
+			assert.Equal(t, tc.want, names(tt))
+		})
+	}
+	_, err := matcher("/(/")
+	assert.Error(t, err)
+}
+
+func TestPrint(t *testing.T) {
+	entries := testEntries(t)
+	all, _ := matcher("")
+	tt := tags(entries, tag.Rollup(tag.MakeTagmap(entries)), all)
+	sortTags(tt, COUNT, true)
+
+	w := bytes.Buffer{}
+	assert.NoError(t, printText(&w, tt, true))
+	// children follow their parent, before science-fiction although '-' sorts before '/':
+	expected := `tag              files  first       last
+art              2      2024.02.03  2024.03.02
+science          4      2024.01.01  2024.03.02
+  biology        1      2024.01.04  2024.01.04
+  physics        2      2024.02.03  2024.03.02
+    optics       1      2024.02.03  2024.02.03
+science-fiction  1      2024.01.04  2024.01.04
+undated          1      -           -
+`
+	assert.Equal(t, expected, w.String())
+
+	w.Reset()
+	assert.NoError(t, printJSON(&w, tt))
+	got := []Tag{}
+	assert.NoError(t, json.Unmarshal(w.Bytes(), &got))
+	assert.Equal(t, tt, got)
+}
@@ -1,60 +1,154 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"os/exec"
+	"strings"
 
-	"github.com/brtholomy/um/go/cat"
 	"github.com/brtholomy/um/go/cmd"
-	"github.com/brtholomy/um/go/last"
-	"github.com/brtholomy/um/go/mv"
-	"github.com/brtholomy/um/go/next"
-	"github.com/brtholomy/um/go/sort"
-	"github.com/brtholomy/um/go/tag"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/plugin"
+
+	// each subcommand registers itself:
+	_ "github.com/brtholomy/um/go/cat"
+	_ "github.com/brtholomy/um/go/completion"
+	_ "github.com/brtholomy/um/go/graph"
+	_ "github.com/brtholomy/um/go/last"
+	_ "github.com/brtholomy/um/go/mv"
+	_ "github.com/brtholomy/um/go/next"
+	_ "github.com/brtholomy/um/go/related"
+	_ "github.com/brtholomy/um/go/serve"
+	_ "github.com/brtholomy/um/go/site"
+	_ "github.com/brtholomy/um/go/sort"
+	_ "github.com/brtholomy/um/go/stats"
+	_ "github.com/brtholomy/um/go/tag"
+	_ "github.com/brtholomy/um/go/tags"
+	_ "github.com/brtholomy/um/go/uncat"
+	_ "github.com/brtholomy/um/go/watch"
+)
+
+const (
+	HELP_SUMMARY = "show help, or the help of a command"
+	NOT_FOUND    = "um: command not found"
 )
 
-var helpShort string = fmt.Sprintf("um [%s | %s | %s | %s | %s | %s | %s]", cmd.Next, cmd.Last, cmd.Tag, cmd.Cat, cmd.Sort, cmd.Mv, cmd.Help)
-var helpLong string = fmt.Sprintf(`%s
+func init() {
+	cmd.Register(cmd.Command{Name: cmd.Help, Aliases: []cmd.Subcommand{"--help", "-h"}, Summary: HELP_SUMMARY, Run: runHelp})
+}
+
+func helpShort() string {
+	names := []string{}
+	for _, c := range cmd.Commands() {
+		names = append(names, string(c.Name))
+	}
+	return fmt.Sprintf("um [%s]", strings.Join(names, " | "))
+}
+
+func helpLong() string {
+	sb := strings.Builder{}
+	for _, c := range cmd.Commands() {
+		sb.WriteString(fmt.Sprintf("  %-12s%s\n", c.Name, c.Summary))
+	}
+	plugins := strings.Builder{}
+	for _, p := range plugin.List() {
+		// a builtin shadows a plugin of the same name:
+		if _, ok := cmd.Lookup(p.Name); ok {
+			continue
+		}
+		plugins.WriteString(fmt.Sprintf("  %-12s%s\n", p.Name, p.Path))
+	}
+	if plugins.Len() > 0 {
+		sb.WriteString("\nplugins:\n" + plugins.String())
+	}
+	return fmt.Sprintf(`%s
 
 (U)ltralight zettelkasten for (M)arkdown composition.
 
+%s
 Each subcommand has a --help | -h flag.
 
 https://github.com/brtholomy/um
-`, helpShort)
+`, helpShort(), sb.String())
+}
+
+// um help [command] is the same as um command --help.
+func runHelp(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) > 0 {
+		if c, ok := cmd.Lookup(args[0]); ok && c.Name != cmd.Help {
+			return c.Run(ctx, []string{"--help"}, stdin, stdout, stderr)
+		}
+	}
+	fmt.Fprintln(stdout, helpLong())
+	return nil
+}
 
 func main() {
-	// NOTE: no prefix at all so that I can use log alongside fmt
-	// log : stderr : exit status 1
-	// fmt : stdout : exit status 0
-	log.SetFlags(0)
+	// NOTE: stdout for results, stderr for everything else, so that results can be piped:
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	if len(os.Args) < 2 {
-		log.Fatalln(helpShort)
+// dispatches to the subcommand and returns the exit status.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprintln(stderr, helpShort())
+		return cmd.EXIT_USAGE
 	}
 
-	arg := cmd.Subcommand(os.Args[1])
+	c, ok := cmd.Lookup(args[0])
+	if !ok {
+		// an unknown command may be a plugin:
+		if p, ok := plugin.Find(args[0]); ok {
+			err := p.Run(ctx, args[1:], stdin, stdout, stderr)
+			return report(stdout, stderr, cmd.Subcommand(p.Name), err)
+		}
+		fmt.Fprintf(stderr, "%s: %s\n", NOT_FOUND, args[0])
+		if suggested := cmd.Suggest(args[0]); len(suggested) > 0 {
+			fmt.Fprintf(stderr, "did you mean: %s?\n", joinNames(suggested))
+		}
+		fmt.Fprintln(stderr, helpShort())
+		return cmd.EXIT_USAGE
+	}
 	// NOTE: just pass what's relevant:
-	args := os.Args[2:]
-
-	switch arg {
-	case cmd.Next:
-		next.Next(args)
-	case cmd.Last:
-		last.Last(args)
-	case cmd.Tag:
-		tag.Tag(args)
-	case cmd.Cat:
-		cat.Cat(args)
-	case cmd.Sort:
-		sort.Sort(args)
-	case cmd.Mv:
-		mv.Mv(args)
-	case cmd.Help:
-		fmt.Println(helpLong)
-	default:
-		log.Println("um: command not found")
-		log.Fatalln(helpShort)
+	err := c.Run(ctx, args[1:], stdin, stdout, stderr)
+	return report(stdout, stderr, c.Name, err)
+}
+
+func joinNames(names []cmd.Subcommand) string {
+	ss := make([]string, len(names))
+	for i, n := range names {
+		ss[i] = string(n)
+	}
+	return strings.Join(ss, " | ")
+}
+
+// prints the error as "um <cmd>: ..." and maps it to an exit status. --help is not an error at all,
+// and goes to stdout.
+func report(stdout, stderr io.Writer, sub cmd.Subcommand, err error) int {
+	if err == nil {
+		return cmd.EXIT_OK
+	}
+	// a plugin has already said what went wrong:
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		if code := exit.ExitCode(); code > 0 {
+			return code
+		}
+		return cmd.EXIT_FAILURE
+	}
+	var help flags.HelpError
+	if errors.As(err, &help) {
+		if help.Requested() {
+			fmt.Fprintln(stdout, help)
+			return cmd.EXIT_OK
+		}
+		// NOTE: already prefixed:
+		fmt.Fprintln(stderr, help)
+		return cmd.ExitCode(err)
 	}
+	fmt.Fprintf(stderr, "um %s: %s\n", sub, err)
+	return cmd.ExitCode(err)
 }
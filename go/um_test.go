@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	assert.NoError(t, os.WriteFile("foo.md", []byte("# foo.md\n\nFoo.\n"), 0664))
+	assert.NoError(t, os.Mkdir("draft", 0775))
+	bad := filepath.Join(dir, "bad.toml")
+	assert.NoError(t, os.WriteFile(bad, []byte("[last\n"), 0664))
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want int
+	}{
+		{"help", nil, []string{"help"}, cmd.EXIT_OK},
+		{"--help", nil, []string{"sort", "--help"}, cmd.EXIT_OK},
+		{"no command", nil, nil, cmd.EXIT_USAGE},
+		{"unknown command", nil, []string{"nope"}, cmd.EXIT_USAGE},
+		{"unknown flag", nil, []string{"sort", "--nope"}, cmd.EXIT_USAGE},
+		{"required flag", nil, []string{"sort"}, cmd.EXIT_USAGE},
+		{"invalid value", nil, []string{"completion", "csh"}, cmd.EXIT_USAGE},
+		{"completion of every command", nil, []string{"completion", "bash"}, cmd.EXIT_OK},
+		{"no um files", nil, []string{"last"}, cmd.EXIT_NOTFOUND},
+		{"missing file", nil, []string{"mv", "01.foo.md", "bar"}, cmd.EXIT_NOTFOUND},
+		{"unnumbered file", nil, []string{"mv", "foo.md", "bar"}, cmd.EXIT_PARSE},
+		{"bad config", map[string]string{"UM_CONFIG": bad}, []string{"tags"}, cmd.EXIT_PARSE},
+		{"help despite a bad config", map[string]string{"UM_CONFIG": bad}, []string{"tags", "--help"}, cmd.EXIT_OK},
+		{"read a directory", nil, []string{"uncat", "draft", "list.um"}, cmd.EXIT_IO},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			assert.Equal(t, tc.want, run(context.Background(), tc.args, nil, io.Discard, io.Discard))
+		})
+	}
+}
+
+// runs um in-process, returning the exit status and what went to stdout and stderr.
+func runCapture(stdin string, args ...string) (int, string, string) {
+	var in io.Reader
+	if stdin != "" {
+		in = strings.NewReader(stdin)
+	}
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	code := run(context.Background(), args, in, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunInProcess(t *testing.T) {
+	t.Chdir(t.TempDir())
+	assert.NoError(t, os.WriteFile("01.foo.md", []byte("# 01.foo.md\n: 2024.09.25\n+ bar\n\nFoo.\n"), 0664))
+	assert.NoError(t, os.WriteFile("02.bar.md", []byte("# 02.bar.md\n: 2024.09.26\n+ bar\n\nBar.\n"), 0664))
+
+	code, out, _ := runCapture("", "last")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	assert.Equal(t, "02.bar.md\n", out)
+
+	code, out, _ = runCapture("02.bar.md\n01.foo.md\n", "cat")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	assert.Equal(t, "\n---\n\nBar.\n\n---\n\nFoo.\n", out)
+
+	// as from find -print0:
+	code, out, _ = runCapture("./02.bar.md\x00./01.foo.md\x00", "sort", "-0", "--by", "number")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	assert.Equal(t, "./01.foo.md\n./02.bar.md\n", out)
+
+	// comments, blank lines and CRLF endings in a filelist, with - for stdin:
+	assert.NoError(t, os.WriteFile("list.um", []byte("# part one\r\n01.foo.md\r\n\r\n"), 0664))
+	code, out, _ = runCapture("02.bar.md\n", "cat", "list.um", "-")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	assert.Equal(t, "\n---\n\nFoo.\n\n---\n\nBar.\n", out)
+
+	code, out, errs := runCapture("02.bar.md\n", "sort", "--key", "/dev/null")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	assert.Equal(t, "02.bar.md\n", out)
+	assert.Equal(t, "um sort: added: 02.bar.md\n", errs)
+}
+
+func TestRunHelp(t *testing.T) {
+	code, out, _ := runCapture("", "help")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	// every registered command is listed with its summary:
+	for _, c := range cmd.Commands() {
+		assert.Contains(t, out, string(c.Name))
+		assert.Contains(t, out, c.Summary)
+	}
+
+	code, out, _ = runCapture("", "help", "sort")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	assert.Contains(t, out, "um sort")
+	assert.Contains(t, out, "--key")
+
+	code, alias, _ := runCapture("", "--help")
+	assert.Equal(t, cmd.EXIT_OK, code)
+	_, out, _ = runCapture("", "help")
+	assert.Equal(t, out, alias)
+}
+
+func TestRunSuggest(t *testing.T) {
+	cases := []struct {
+		arg  string
+		want string
+	}{
+		{"srot", "did you mean: sort?"},
+		{"ta", "did you mean: tag | tags?"},
+		{"tgas", "did you mean: tags?"},
+		{"un", "did you mean: uncat?"},
+		{"xyzzy", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.arg, func(t *testing.T) {
+			code, _, errs := runCapture("", tc.arg)
+			assert.Equal(t, cmd.EXIT_USAGE, code)
+			assert.Contains(t, errs, NOT_FOUND+": "+tc.arg)
+			if tc.want == "" {
+				assert.NotContains(t, errs, "did you mean")
+				return
+			}
+			assert.Contains(t, errs, tc.want)
+		})
+	}
+}
+
+func TestRunPlugin(t *testing.T) {
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\"\nexit 7\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "um-publish"), []byte(script), 0755))
+	// shadowed by the builtin:
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "um-last"), []byte(script), 0755))
+	t.Setenv("PATH", bin)
+	t.Chdir(t.TempDir())
+
+	code, out, _ := runCapture("", "publish", "--to", "web")
+	assert.Equal(t, 7, code)
+	assert.Equal(t, "--to web\n", out)
+
+	code, _, _ = runCapture("", "last")
+	assert.Equal(t, cmd.EXIT_NOTFOUND, code)
+
+	_, out, _ = runCapture("", "help")
+	assert.Contains(t, out, "plugins:")
+	assert.Contains(t, out, filepath.Join(bin, "um-publish"))
+	assert.NotContains(t, out, filepath.Join(bin, "um-last"))
+}
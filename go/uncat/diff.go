@@ -0,0 +1,125 @@
+package uncat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brtholomy/um/go/pipe"
+)
+
+// lines of unchanged context around each hunk, as in diff -u
+const CONTEXT = 3
+
+type op struct {
+	kind byte
+	line string
+}
+
+// split keeping the newline, so that a missing final newline shows up in the diff:
+func lines(s string) []string {
+	ll := strings.SplitAfter(s, pipe.Newline)
+	if ll[len(ll)-1] == "" {
+		ll = ll[:len(ll)-1]
+	}
+	return ll
+}
+
+// the edit script from a to b via the longest common subsequence. um files are small enough that the
+// quadratic table doesn't matter.
+func edits(a, b []string) []op {
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
+
+// a minimal unified diff of a to b, enough to preview what --write would do.
+func Unified(name, a, b string) string {
+	ops := edits(lines(a), lines(b))
+
+	// line numbers in a and b at the start of each op:
+	apos := make([]int, len(ops)+1)
+	bpos := make([]int, len(ops)+1)
+	for k, o := range ops {
+		apos[k+1], bpos[k+1] = apos[k], bpos[k]
+		if o.kind != '+' {
+			apos[k+1]++
+		}
+		if o.kind != '-' {
+			bpos[k+1]++
+		}
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("--- a/%s\n+++ b/%s\n", name, name))
+	for k := 0; k < len(ops); {
+		for k < len(ops) && ops[k].kind == ' ' {
+			k++
+		}
+		if k == len(ops) {
+			break
+		}
+		from := max(0, k-CONTEXT)
+		// extend the hunk while the next change is close enough that the contexts would overlap:
+		to := k
+		for {
+			for to < len(ops) && ops[to].kind != ' ' {
+				to++
+			}
+			next := to
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-to > 2*CONTEXT {
+				break
+			}
+			to = next
+		}
+		to = min(len(ops), to+CONTEXT)
+
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", apos[from]+1, apos[to]-apos[from], bpos[from]+1, bpos[to]-bpos[from]))
+		for _, o := range ops[from:to] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			if !strings.HasSuffix(o.line, pipe.Newline) {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = to
+	}
+	return sb.String()
+}
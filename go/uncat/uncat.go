@@ -0,0 +1,213 @@
+package uncat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brtholomy/um/go/cat"
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/pipe"
+)
+
+const (
+	CMD     = cmd.Uncat
+	SUMMARY = "split an edited um cat draft back into its source files. previews a diff unless --write"
+)
+
+var ErrMismatch = fmt.Errorf("%w: draft does not match filelist", cmd.ErrParse)
+
+type options struct {
+	Draft        flags.Arg
+	Filelist     flags.Glob
+	Base         flags.String
+	KeepHeader   flags.Bool
+	KeepTitle    flags.Bool
+	Write        flags.Bool
+	Null         flags.Bool
+	StdinTimeout flags.Duration
+	Help         flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Arg{"", "draft produced by um cat. - reads it from stdin", ""},
+		flags.Glob{nil, ".um filelist the draft was built from. accepts multiple. reads from stdin if not provided, or for -", "um"},
+		flags.String{"--base", "-b", "", "base directory prepended to files in filelist", "dirs", &flags.Default{}},
+		flags.Bool{"--keep-header", "-d", false, "the draft was made with um cat --keep-header", &flags.Default{}},
+		flags.Bool{"--keep-title", "-t", false, "the draft was made with um cat --keep-title", &flags.Default{}},
+		flags.Bool{"--write", "-w", false, "write changed sections back to their source files", nil},
+		flags.Bool{"--null", "-0", false, "filelist on stdin is NUL-delimited, as from find -print0", nil},
+		flags.Duration{"--stdin-timeout", "", pipe.STDIN_TIMEOUT, "how long to wait for a filelist on stdin when none is given. 0 waits until EOF", "none", &flags.Default{pipe.STDIN_TIMEOUT_ENV, ""}},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// one source file and the section of the draft it became.
+type section struct {
+	path     string
+	original string
+	// what um cat made of original:
+	catted string
+	edited string
+	// original header reattached to edited:
+	updated string
+}
+
+func (s section) changed() bool {
+	return s.updated != s.original
+}
+
+// splits the draft on the HR_BLOCK separators cat inserted.
+//
+// a source file may contain its own horizontal rules, so each section consumes as many pieces as its
+// catted form produces. any other difference in count means the structure was edited, and we refuse.
+func split(draft string, sections []section) error {
+	body, ok := strings.CutPrefix(draft, cat.HR_BLOCK)
+	if !ok {
+		return fmt.Errorf("%w: missing leading horizontal rule", ErrMismatch)
+	}
+	pieces := strings.Split(body, cat.HR_BLOCK)
+
+	want := 0
+	for _, s := range sections {
+		want += strings.Count(s.catted, cat.HR_BLOCK) + 1
+	}
+	if len(pieces) != want {
+		return fmt.Errorf("%w: %d sections in draft, expected %d from %d files", ErrMismatch, len(pieces), want, len(sections))
+	}
+
+	k := 0
+	for i := range sections {
+		n := strings.Count(sections[i].catted, cat.HR_BLOCK) + 1
+		sections[i].edited = strings.Join(pieces[k:k+n], cat.HR_BLOCK)
+		k += n
+	}
+	return nil
+}
+
+// the inverse of cat.Decapitate: reattach the original header to the edited section.
+func recapitate(original, edited string, opts options) (string, error) {
+	if opts.KeepHeader.IsSet() || !strings.HasPrefix(original, cat.H1) {
+		return edited, nil
+	}
+	head, _, ok := strings.Cut(original, cat.DOUBLE_NEWLINE)
+	if !ok {
+		// a header-only file was catted whole:
+		return edited, nil
+	}
+	if opts.KeepTitle.IsSet() {
+		title, _, _ := strings.Cut(head, pipe.Newline)
+		body, ok := strings.CutPrefix(edited, title+cat.DOUBLE_NEWLINE)
+		if !ok {
+			return "", fmt.Errorf("%w: title not found at start of section: %s", ErrMismatch, title)
+		}
+		edited = body
+	}
+	return head + cat.DOUBLE_NEWLINE + edited, nil
+}
+
+// maps the draft back onto the files in the filelist.
+func uncat(draft string, files []string, opts options) ([]section, error) {
+	sections := make([]section, 0, len(files))
+	for _, f := range files {
+		bf := filepath.Join(opts.Base.Val, f)
+		dat, err := os.ReadFile(bf)
+		if err != nil {
+			return nil, fmt.Errorf("error opening source file: %w", err)
+		}
+		s := string(dat)
+		sections = append(sections, section{
+			path:     bf,
+			original: s,
+			catted:   cat.Decapitate(s, opts.KeepHeader.Val, opts.KeepTitle.Val),
+		})
+	}
+	if err := split(draft, sections); err != nil {
+		return nil, err
+	}
+
+	// the same file listed twice must not be edited two different ways:
+	seen := map[string]string{}
+	for i := range sections {
+		updated, err := recapitate(sections[i].original, sections[i].edited, opts)
+		if err != nil {
+			return nil, err
+		}
+		sections[i].updated = updated
+		if prev, ok := seen[sections[i].path]; ok && prev != updated {
+			return nil, fmt.Errorf("%w: conflicting edits to repeated file: %s", ErrMismatch, sections[i].path)
+		}
+		seen[sections[i].path] = updated
+	}
+	return sections, nil
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	stdin = pipe.WithTimeout(stdin, opts.StdinTimeout.Val)
+	// BORK: by hand for now:
+	if !opts.Draft.IsSet() {
+		return help.HelpRequired("[draft]")
+	}
+
+	var dat []byte
+	var err error
+	if opts.Draft.Val == pipe.STDIN {
+		if stdin == nil {
+			return fmt.Errorf("%w: %s given but there is no stdin", cmd.ErrUsage, pipe.STDIN)
+		}
+		dat, err = io.ReadAll(stdin)
+		// the filelist can't come from stdin too:
+		stdin = nil
+	} else {
+		dat, err = os.ReadFile(opts.Draft.Val)
+	}
+	if err != nil {
+		return fmt.Errorf("error opening draft: %w", err)
+	}
+	files, err := pipe.FileListFromGlobOrStdin(stdin, opts.Filelist.Val, pipe.Sep(opts.Null.Val))
+	if err != nil {
+		return err
+	}
+	sections, err := uncat(string(dat), files, opts)
+	if err != nil {
+		return err
+	}
+
+	done := map[string]bool{}
+	for _, s := range sections {
+		if !s.changed() || done[s.path] {
+			continue
+		}
+		done[s.path] = true
+		if !opts.Write.IsSet() {
+			fmt.Fprint(stdout, Unified(s.path, s.original, s.updated))
+			continue
+		}
+		if err := pipe.WriteFile(s.path, []byte(s.updated)); err != nil {
+			return fmt.Errorf("error writing file: %s: %w", s.path, err)
+		}
+		// NOTE: to stdout so the changed files can be piped onward:
+		fmt.Fprintln(stdout, s.path)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package uncat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brtholomy/um/go/cat"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	FOO = "# 01.foo.md\n: 2024.09.25\n+ bar\n\nFoo bar.\n"
+	BAR = "# 02.bar.md\n: 2024.09.25\n\nBar.\n\n---\n\nAfter a rule.\n"
+)
+
+// writes the source files into a temp dir and returns the options pointing there.
+func setup(t *testing.T) options {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "01.foo.md"), []byte(FOO), 0664))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "02.bar.md"), []byte(BAR), 0664))
+	opts := initOpts()
+	opts.Base.Val = dir
+	return opts
+}
+
+func draft(keepTitle bool, contents ...string) string {
+	ff := []string{}
+	for _, c := range contents {
+		ff = append(ff, cat.Decapitate(c, false, keepTitle))
+	}
+	return cat.HR_BLOCK + strings.Join(ff, cat.HR_BLOCK)
+}
+
+func TestUncatUnchanged(t *testing.T) {
+	opts := setup(t)
+	sections, err := uncat(draft(false, FOO, BAR), []string{"01.foo.md", "02.bar.md"}, opts)
+	assert.NoError(t, err)
+	assert.Len(t, sections, 2)
+	for _, s := range sections {
+		assert.False(t, s.changed())
+	}
+}
+
+func TestUncatEdited(t *testing.T) {
+	opts := setup(t)
+	d := strings.Replace(draft(false, FOO, BAR), "After a rule.", "After an edited rule.", 1)
+	sections, err := uncat(d, []string{"01.foo.md", "02.bar.md"}, opts)
+	assert.NoError(t, err)
+	assert.False(t, sections[0].changed())
+	assert.True(t, sections[1].changed())
+	assert.Equal(t, "# 02.bar.md\n: 2024.09.25\n\nBar.\n\n---\n\nAfter an edited rule.\n", sections[1].updated)
+}
+
+func TestUncatKeepTitle(t *testing.T) {
+	opts := setup(t)
+	opts.KeepTitle.Val = true
+	d := strings.Replace(draft(true, FOO, BAR), "Foo bar.", "Foo baz.", 1)
+	sections, err := uncat(d, []string{"01.foo.md", "02.bar.md"}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "# 01.foo.md\n: 2024.09.25\n+ bar\n\nFoo baz.\n", sections[0].updated)
+	assert.False(t, sections[1].changed())
+}
+
+func TestUncatMismatch(t *testing.T) {
+	opts := setup(t)
+	cases := []struct {
+		name  string
+		draft string
+	}{
+		{"extra rule", draft(false, FOO, BAR) + cat.HR_BLOCK + "New.\n"},
+		{"missing file", draft(false, FOO)},
+		{"no leading rule", strings.TrimPrefix(draft(false, FOO, BAR), cat.HR_BLOCK)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := uncat(tc.draft, []string{"01.foo.md", "02.bar.md"}, opts)
+			assert.ErrorIs(t, err, ErrMismatch)
+		})
+	}
+}
+
+func TestUncatConflict(t *testing.T) {
+	opts := setup(t)
+	d := draft(false, FOO, FOO)
+	d = strings.Replace(d, "Foo bar.", "Foo baz.", 1)
+	_, err := uncat(d, []string{"01.foo.md", "01.foo.md"}, opts)
+	assert.ErrorIs(t, err, ErrMismatch)
+}
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\n"
+	b := "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nnine\n"
+	expected := `--- a/f
++++ b/f
+@@ -2,7 +2,7 @@
+ two
+ three
+ four
+-five
++FIVE
+ six
+ seven
+ eight
+`
+	assert.Equal(t, expected, Unified("f", a, b))
+}
@@ -0,0 +1,336 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brtholomy/um/go/cmd"
+	"github.com/brtholomy/um/go/config"
+	"github.com/brtholomy/um/go/flags"
+	"github.com/brtholomy/um/go/last"
+	"github.com/brtholomy/um/go/pipe"
+	"github.com/brtholomy/um/go/sort"
+	"github.com/brtholomy/um/go/tag"
+)
+
+const (
+	CMD     = cmd.Watch
+	SUMMARY = "keep filelists generated by a query fresh as um files change"
+)
+
+// the config section registering filelists, each with the tag query it holds, or the name of a saved
+// one. a filelist declaring its own query in a header needs no registering:
+//
+//	[lists]
+//	foo.um = foo+bar
+//	essays.um = @essays
+const LISTS_SECTION = "lists"
+
+type options struct {
+	Interval flags.Duration
+	Debounce flags.Duration
+	Once     flags.Bool
+	Help     flags.Bool
+}
+
+func initOpts() options {
+	return options{
+		flags.Duration{"--interval", "-n", time.Second, "how often to look for changed files", "none", &flags.Default{}},
+		flags.Duration{"--debounce", "-d", 2 * time.Second, "how long files must stay unchanged before the lists are refreshed. longer than --interval", "none", &flags.Default{}},
+		flags.Bool{"--once", "-o", false, "refresh the lists once and exit, instead of watching", nil},
+		flags.Bool{"--help", "-h", false, "show help", nil},
+	}
+}
+
+// exported for um completion.
+func Options() any {
+	opts := initOpts()
+	return &opts
+}
+
+func init() {
+	cmd.Register(cmd.Command{Name: CMD, Summary: SUMMARY, Options: Options, Run: Run})
+}
+
+// what a change is noticed by. an editor saving twice within the same mtime tick has usually changed
+// the size as well.
+type stamp struct {
+	mod  int64
+	size int64
+}
+
+type cached struct {
+	stamp stamp
+	entry tag.Entry
+}
+
+// polls the collection and keeps its parsed entries, so that a change to one file reparses only that
+// file.
+type watcher struct {
+	// the stamps of the um files and the meta files, as of the last poll:
+	seen map[string]stamp
+	// what each entry was parsed from. one whose stamp differs from seen is stale:
+	entries map[string]cached
+	// the stamps of the meta files as of the last sync. nil until then:
+	meta map[string]stamp
+	// filelist -> query, from the config:
+	lists  map[string]string
+	stderr io.Writer
+}
+
+func newWatcher(stderr io.Writer) *watcher {
+	return &watcher{seen: map[string]stamp{}, entries: map[string]cached{}, stderr: stderr}
+}
+
+// the files which change how every um file is read, or which lists there are.
+func metaFiles() []string {
+	return slices.DeleteFunc([]string{config.Path(), tag.ALIAS_FILE}, func(f string) bool { return f == "" })
+}
+
+// the filelists under the current directory, leaving out hidden directories. a directory which can't
+// be read is passed to report, if given, and skipped.
+func listFiles(report func(error)) []string {
+	lists := []string{}
+	filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			if report != nil {
+				report(err)
+			}
+			return nil
+		case d.IsDir() && p != "." && strings.HasPrefix(d.Name(), "."):
+			return filepath.SkipDir
+		case !d.IsDir() && filepath.Ext(p) == tag.LIST_EXT:
+			lists = append(lists, p)
+		}
+		return nil
+	})
+	return lists
+}
+
+func isList(f string) bool {
+	return filepath.Ext(f) == tag.LIST_EXT
+}
+
+// stats the um files, the filelists and the meta files, true if anything was added, removed or
+// changed since the last poll. so a new filelist declaring its query is noticed as soon as it's
+// written. a meta file that doesn't exist is simply absent.
+func (w *watcher) poll() (bool, error) {
+	filelist, err := filepath.Glob(last.GLOB)
+	if err != nil {
+		return false, err
+	}
+	// NOTE: findLists reports what can't be read, rather than every poll:
+	filelist = append(filelist, listFiles(nil)...)
+	seen := make(map[string]stamp, len(filelist))
+	for _, f := range append(filelist, metaFiles()...) {
+		// NOTE: a file removed since the glob is removed as far as we are concerned:
+		if st, err := os.Stat(f); err == nil {
+			seen[f] = stamp{st.ModTime().UnixNano(), st.Size()}
+		}
+	}
+	changed := !maps.Equal(seen, w.seen)
+	w.seen = seen
+	return changed, nil
+}
+
+// takes in a filelist we just wrote, so that the next poll doesn't count it as changed.
+func (w *watcher) restamp(list string) {
+	if st, err := os.Stat(list); err == nil {
+		w.seen[list] = stamp{st.ModTime().UnixNano(), st.Size()}
+	}
+}
+
+// brings the entries up to date with the last poll, parsing only the files that changed since they
+// were last parsed. a changed config or aliases file reloads the lists and reparses everything. returns
+// the files parsed.
+func (w *watcher) sync() ([]string, error) {
+	meta := map[string]stamp{}
+	for _, f := range metaFiles() {
+		if s, ok := w.seen[f]; ok {
+			meta[f] = s
+		}
+	}
+	if w.meta == nil || !maps.Equal(meta, w.meta) {
+		c, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		w.lists = c.Section(LISTS_SECTION)
+		w.meta = meta
+		clear(w.entries)
+	}
+
+	maps.DeleteFunc(w.entries, func(f string, _ cached) bool {
+		_, ok := w.seen[f]
+		return !ok
+	})
+	stale := []string{}
+	for f, s := range w.seen {
+		if _, ok := meta[f]; ok || isList(f) {
+			continue
+		}
+		if c, ok := w.entries[f]; !ok || c.stamp != s {
+			stale = append(stale, f)
+		}
+	}
+	slices.Sort(stale)
+	// NOTE: a file removed since the poll fails the lot, which the next poll will notice anyway:
+	entries, err := tag.ParseFiles(stale)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range stale {
+		w.entries[f] = cached{w.seen[f], entries[i]}
+	}
+	return stale, nil
+}
+
+// the entries in filename order, as um tag reads them.
+func (w *watcher) sorted() []tag.Entry {
+	entries := make([]tag.Entry, 0, len(w.entries))
+	for _, f := range slices.Sorted(maps.Keys(w.entries)) {
+		entries = append(entries, w.entries[f].entry)
+	}
+	return entries
+}
+
+// the filelists to keep fresh, each with its query: those registered in the config, and every
+// filelist under the current directory declaring its own. one that can't be read is reported, and
+// left out.
+func (w *watcher) findLists() map[string]tag.Saved {
+	lists := map[string]tag.Saved{}
+	for list, q := range w.lists {
+		s := tag.Saved{Query: q}
+		if name, ok := strings.CutPrefix(q, tag.SAVED_PREFIX); ok {
+			var err error
+			if s, err = tag.LoadSaved(name); err != nil {
+				fmt.Fprintf(w.stderr, "um %s: %s: %s\n", CMD, list, err)
+				continue
+			}
+		}
+		lists[filepath.Clean(list)] = s
+	}
+	for _, p := range listFiles(func(err error) { fmt.Fprintf(w.stderr, "um %s: %s\n", CMD, err) }) {
+		// NOTE: the config wins over a header:
+		if _, ok := lists[p]; ok {
+			continue
+		}
+		s, ok, err := tag.ReadHeader(p)
+		if err != nil {
+			fmt.Fprintf(w.stderr, "um %s: %s: %s\n", CMD, p, err)
+		} else if ok {
+			lists[p] = s
+		}
+	}
+	return lists
+}
+
+// refreshes every filelist, and returns how many there are. one which fails is reported, and the rest
+// are refreshed all the same.
+func (w *watcher) refresh(ctx context.Context) int {
+	entries := w.sorted()
+	lists := w.findLists()
+	for _, list := range slices.Sorted(maps.Keys(lists)) {
+		if err := refreshList(ctx, entries, list, lists[list], w.stderr); err != nil {
+			fmt.Fprintf(w.stderr, "um %s: %s: %s\n", CMD, list, err)
+		}
+		w.restamp(list)
+	}
+	return len(lists)
+}
+
+// does what um tag <query> | um sort --key <list> --write does by hand, so that the order of the list
+// is kept and only new files are placed. its header is kept too, since um sort keeps comments in
+// place. um sort takes its defaults as usual, so a --merge strategy in the config applies here too.
+func refreshList(ctx context.Context, entries []tag.Entry, list string, saved tag.Saved, stderr io.Writer) error {
+	files, err := saved.Filelist(entries)
+	if err != nil {
+		return err
+	}
+	filelist := strings.Join(files, pipe.Newline)
+	// a new list starts as an empty key:
+	if _, err := os.Stat(list); errors.Is(err, fs.ErrNotExist) {
+		if err := pipe.WriteFile(list, nil); err != nil {
+			return fmt.Errorf("error writing file: %s: %w", list, err)
+		}
+	}
+	args := []string{"--key", list, "--write", pipe.STDIN}
+	return sort.Run(ctx, args, strings.NewReader(filelist), io.Discard, stderr)
+}
+
+// polls, syncs and refreshes the lists. returns how many there are.
+func (w *watcher) update(ctx context.Context) (int, error) {
+	if _, err := w.poll(); err != nil {
+		return 0, err
+	}
+	if _, err := w.sync(); err != nil {
+		return 0, err
+	}
+	return w.refresh(ctx), nil
+}
+
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	opts := initOpts()
+	help := flags.NewHelpError(CMD, SUMMARY)
+	if err := flags.ParseArgs(help, args, &opts); err != nil {
+		return err
+	}
+	if opts.Interval.Val <= 0 {
+		return help.HelpInvalidValue(opts.Interval.Long, opts.Interval.Val.String())
+	}
+	// a change is only seen by a poll, so a shorter debounce would refresh on every poll of a burst:
+	if opts.Debounce.Val <= opts.Interval.Val {
+		return help.HelpInvalidValue(opts.Debounce.Long, opts.Debounce.Val.String())
+	}
+
+	w := newWatcher(stderr)
+	// NOTE: only a failure to start is an error. after that, the watch goes on and reports:
+	n, err := w.update(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Fprintf(stderr, "um %s: no filelists in [%s] of the config, nor any declaring a %s\n", CMD, LISTS_SECTION, tag.QUERY_KEY)
+	}
+	if opts.Once.Val {
+		return nil
+	}
+
+	ticker := time.NewTicker(opts.Interval.Val)
+	defer ticker.Stop()
+	// restarted by every poll which sees a change, so that a burst of saves only refreshes once it's
+	// over:
+	debounce := time.NewTimer(opts.Debounce.Val)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			ok, err := w.poll()
+			if err != nil {
+				return err
+			}
+			if ok {
+				debounce.Reset(opts.Debounce.Val)
+			}
+		case <-debounce.C:
+			if _, err := w.sync(); err != nil {
+				fmt.Fprintf(stderr, "um %s: %s\n", CMD, err)
+				continue
+			}
+			w.refresh(ctx)
+		}
+	}
+}
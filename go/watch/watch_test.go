@@ -0,0 +1,199 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brtholomy/um/go/config"
+)
+
+func writeFile(t *testing.T, name string, tags ...string) {
+	t.Helper()
+	content := fmt.Sprintf("# %s\n: 2024.09.25\n", name)
+	for _, tag := range tags {
+		content += "+ " + tag + "\n"
+	}
+	assert.NoError(t, os.WriteFile(name, []byte(content+"\nBody.\n"), 0644))
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	dat, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	return string(dat)
+}
+
+// the collection shared by the tests of serve, site and watch, whose config registers foo.um.
+const (
+	COLLECTION = "../testdata/collection"
+	CONFIG     = "config.toml"
+)
+
+// the shared collection, copied into a temporary directory with its config in effect.
+func collection(t *testing.T) {
+	src, err := filepath.Abs(COLLECTION)
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	assert.NoError(t, os.CopyFS(dir, os.DirFS(src)))
+	t.Chdir(dir)
+	t.Setenv(config.ENV, filepath.Join(dir, CONFIG))
+}
+
+func TestSync(t *testing.T) {
+	collection(t)
+	w := newWatcher(&bytes.Buffer{})
+
+	changed, err := w.poll()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	parsed, err := w.sync()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.foo.md", "02.bar.md", "03.baz.md", "04.md"}, parsed)
+	assert.Equal(t, map[string]string{"foo.um": "foo"}, w.lists)
+
+	// nothing changed, nothing parsed:
+	changed, err = w.poll()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	parsed, err = w.sync()
+	assert.NoError(t, err)
+	assert.Empty(t, parsed)
+
+	// only the changed file is parsed again, and a removed one is dropped:
+	writeFile(t, "02.bar.md", "bar", "foo")
+	assert.NoError(t, os.Remove("03.baz.md"))
+	changed, err = w.poll()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	parsed, err = w.sync()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"02.bar.md"}, parsed)
+	assert.ElementsMatch(t, []string{"01.foo.md", "02.bar.md", "04.md"}, slices.Collect(maps.Keys(w.entries)))
+
+	// new aliases change every file:
+	assert.NoError(t, os.WriteFile(".umtags", []byte("[aliases]\nbar = foo\n"), 0644))
+	_, err = w.poll()
+	assert.NoError(t, err)
+	parsed, err = w.sync()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"01.foo.md", "02.bar.md", "04.md"}, parsed)
+
+	// a new filelist is a change, but not an entry:
+	assert.NoError(t, os.WriteFile("new.um", []byte("# query = bar\n"), 0644))
+	changed, err = w.poll()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	parsed, err = w.sync()
+	assert.NoError(t, err)
+	assert.Empty(t, parsed)
+	assert.NotContains(t, w.entries, "new.um")
+}
+
+func TestRefresh(t *testing.T) {
+	collection(t)
+	stderr := &bytes.Buffer{}
+	w := newWatcher(stderr)
+
+	// a missing list is created:
+	_, err := w.update(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "01.foo.md\n02.bar.md\n", readFile(t, "foo.um"))
+	// the lists we wrote are no change:
+	changed, err := w.poll()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// a hand-sorted list keeps its order, and a new file is appended:
+	assert.NoError(t, os.WriteFile("foo.um", []byte("02.bar.md\n01.foo.md\n"), 0644))
+	writeFile(t, "05.foo.md", "foo")
+	_, err = w.update(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "02.bar.md\n01.foo.md\n05.foo.md\n", readFile(t, "foo.um"))
+	assert.Contains(t, stderr.String(), "added: 05.foo.md")
+
+	// a bad query is reported, and the other lists are refreshed all the same:
+	assert.NoError(t, os.WriteFile(CONFIG, []byte("[lists]\nbad.um = /(/\nbar.um = bar\n"), 0644))
+	_, err = w.update(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, stderr.String(), "um watch: bad.um: ")
+	assert.Equal(t, "02.bar.md\n", readFile(t, "bar.um"))
+}
+
+func TestRefreshSaved(t *testing.T) {
+	collection(t)
+	conf := "[lists]\nnotbar.um = @notbar\nmissing.um = @missing\n\n[query.notbar]\nquery = bar\ninvert = true\n"
+	assert.NoError(t, os.WriteFile(CONFIG, []byte(conf), 0644))
+	// a filelist declaring its own query, in a subdirectory, keeps its header and its order:
+	assert.NoError(t, os.Mkdir("lists", 0755))
+	assert.NoError(t, os.WriteFile("lists/foo.um", []byte("# query = foo\n# kept\n02.bar.md\n"), 0644))
+	// and one declaring none is left alone:
+	assert.NoError(t, os.WriteFile("plain.um", []byte("# a note\n02.bar.md\n"), 0644))
+	stderr := &bytes.Buffer{}
+	w := newWatcher(stderr)
+
+	n, err := w.update(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "01.foo.md\n03.baz.md\n04.md\n", readFile(t, "notbar.um"))
+	assert.Equal(t, "# query = foo\n# kept\n02.bar.md\n01.foo.md\n", readFile(t, "lists/foo.um"))
+	assert.Equal(t, "# a note\n02.bar.md\n", readFile(t, "plain.um"))
+	assert.Contains(t, stderr.String(), "um watch: missing.um: not found: no saved query: @missing")
+}
+
+func TestRun(t *testing.T) {
+	collection(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, []string{"--interval", "10ms", "--debounce", "30ms"}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	}()
+
+	assert.Eventually(t, func() bool {
+		dat, _ := os.ReadFile("foo.um")
+		return string(dat) == "01.foo.md\n02.bar.md\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	writeFile(t, "05.foo.md", "foo")
+	assert.Eventually(t, func() bool {
+		dat, _ := os.ReadFile("foo.um")
+		return string(dat) == "01.foo.md\n02.bar.md\n05.foo.md\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	// a new filelist declaring its query is picked up without any um file changing:
+	assert.NoError(t, os.WriteFile("bar.um", []byte("# query = bar\n"), 0644))
+	assert.Eventually(t, func() bool {
+		dat, _ := os.ReadFile("bar.um")
+		return string(dat) == "# query = bar\n02.bar.md\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRunOnce(t *testing.T) {
+	collection(t)
+	assert.NoError(t, os.WriteFile(CONFIG, nil, 0644))
+	stderr := &bytes.Buffer{}
+	assert.NoError(t, Run(context.Background(), []string{"--once"}, nil, &bytes.Buffer{}, stderr))
+	assert.Equal(t, "um watch: no filelists in [lists] of the config, nor any declaring a query\n", stderr.String())
+
+	assert.Error(t, Run(context.Background(), []string{"--interval", "0s"}, nil, &bytes.Buffer{}, stderr))
+	assert.Error(t, Run(context.Background(), []string{"--debounce", "-1s"}, nil, &bytes.Buffer{}, stderr))
+	// a debounce no longer than the interval would refresh on every poll of a burst:
+	assert.Error(t, Run(context.Background(), []string{"--interval", "1s", "--debounce", "1s"}, nil, &bytes.Buffer{}, stderr))
+	assert.Error(t, Run(context.Background(), []string{"--interval", "5s"}, nil, &bytes.Buffer{}, stderr))
+}
+
+func TestDefaults(t *testing.T) {
+	opts := initOpts()
+	assert.Greater(t, opts.Debounce.Val, opts.Interval.Val)
+	collection(t)
+	assert.NoError(t, Run(context.Background(), []string{"--once"}, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+}